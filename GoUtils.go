@@ -1,34 +1,21 @@
 package utils
 
-// #cgo CFLAGS: -g -Wall
-// #include <stdio.h>
-// #include <stdlib.h>
-// #include <string.h>
-// #include "cutils.h"
-import "C"
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path"
-	"path/filepath"
-	"runtime"
-	"runtime/debug"
-	"runtime/pprof"
 	"strconv"
 	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/valyala/fasthttp"
 
-	"github.com/pierrec/lz4"
 	log "github.com/sirupsen/logrus" // Pretty log library, not the fastest (zerolog/zap)
-	"github.com/valyala/gozstd"
 )
 
 //Monitor is the data structure for store the metrics data
@@ -46,117 +33,35 @@ type Monitor struct {
 	NumGoroutine  int
 }
 
-/* ==== C wrappers ==== */
-
-// C methods for speedup the code
-
-// GetFileSizeC wrapper method for retrieve byte lenght of a file
-func GetFileSizeC(filename string) int64 {
-	// Cast a string to a 'C string'
-	fname := C.CString(filename)
-	defer C.free(unsafe.Pointer(fname))
-	// get the file size of the file
-	size := C.get_file_size(fname)
-	return int64(size)
-}
-
-// SpellCheck is a Go wrapper for the C spell check algorithm
-func SpellCheck(filepath, wrongword string) string {
-	log.Debug("Reading data from [", filepath, "]")
-	dictPath := C.CString(filepath)
-	wrongWord := C.CString(wrongword)
-	correct := C.spell_check(dictPath, 102264, wrongWord)
-	str := C.GoString(correct)
-	C.free(unsafe.Pointer(dictPath))
-	C.free(unsafe.Pointer(wrongWord))
-	return str
-}
-
-
-// ReadFileContentC wrapper method for retrieve content by a file
-func ReadFileContentC(filename string) string {
-	// Cast a string to a 'C string'
-	fname := C.CString(filename)
-	// recognize the size of the file
-	fsize := GetFileSizeC(filename)
-	// Allocate the string for the result
-	ptr := C.malloc(C.sizeof_char * C.ulong(fsize))
-	defer C.free(unsafe.Pointer(ptr))
-
-	// Cast the pointer in order to suit the method signature
-	C.read_content_no_alloc(fname, (*C.char)(ptr))
-	// Cast the fsize to int
-	b := C.GoBytes(ptr, C.int(fsize))
-	return string(b)
-}
-
-// CompareData is Golang wrapper for the C method delegated to verify if the given target is present in the data
-func CompareData(data, toFind string) bool {
-	ret := C.verify_presence_data(C.CString(data), C.CString(toFind))
-	//log.Error("CompareData | Ret: ", ret)
-	return ret == 1
-}
-
-// CompareDataInsensitive call the C function delegated to 'lowerize' the string and find the first occurence
-func CompareDataInsensitive(data, toFindLower string) bool {
-	ret := C.verify_presence_data_insensitive(C.CString(data), C.CString(toFindLower))
-	//log.Error("CompareData | Ret: ", ret)
-	return ret == 1
-}
-
-/* ==== C wrappers ==== */
-
 // Random initalizate a new seed using the UNIX Nano time and return an integer between the 2 input value
 func Random(min int, max int) int {
 	rand.Seed(time.Now().UnixNano())
 	return rand.Intn(max-min) + min
 }
 
-// FreeSystemMemory Call the garbage collector every "gcSleep" minutes
+// FreeSystemMemory log the memory used every "gcSleep" minutes, after a first minute of delay. It never return.
+// gcSleep is read only once: the pointer is not synchronized, so changing the value while running was a data race.
+// NOTE: use a MemoryManager for stop it, change the interval while running (MemoryManager.SetInterval) or free the memory
+// over a soft limit
 func FreeSystemMemory(gcSleep *int) {
-	time.Sleep(1 * time.Minute) // Just relax and load the data
-	var m runtime.MemStats
-	var g debug.GCStats
-	for i := 0; i > -1; {
-		log.Info(printMemUsage(&m, &g))
-		/* debug.FreeOSMemory()
-		log.Info("--- Memory freed! ---")
-		log.Info(printMemUsage(&m, &g)) */
-		time.Sleep(time.Duration(*gcSleep) * time.Minute)
-	}
+	NewMemoryManager(&MemoryManagerOptions{Interval: time.Duration(*gcSleep) * time.Minute, Delay: time.Minute}).Run(context.Background())
 }
 
-// ExportMetrics  is in charge to retrive the information related to the resources used
+// ExportMetrics  is in charge to retrive the information related to the resources used, encoded in JSON.
+// NOTE: the metrics in the OpenMetrics (Prometheus) format are exposed by the MetricsRegistry
 func ExportMetrics() []byte {
-	var m Monitor
-	var rtm runtime.MemStats
-
-	// Read full mem stats
-	runtime.ReadMemStats(&rtm)
-
-	// Number of goroutines
-	m.NumGoroutine = runtime.NumGoroutine()
-
-	// Misc memory stats
-	m.Alloc = rtm.Alloc
-	m.TotalAlloc = rtm.TotalAlloc
-	m.Sys = rtm.Sys
-	m.Mallocs = rtm.Mallocs
-	m.Frees = rtm.Frees
-
-	// Live objects = Mallocs - Frees
-	m.LiveObjects = m.Mallocs - m.Frees
-
-	// GC Stats
-	m.PauseTotalNs = rtm.PauseTotalNs
-	m.MCacheInuse = rtm.MCacheInuse
-	m.NumGC = rtm.NumGC
-	m.GCCPUFraction = rtm.GCCPUFraction
-
-	// Just encode to json and print
-	b, _ := json.Marshal(m)
+	b, err := ExportMetricsE()
+	if err != nil {
+		log.Error("ExportMetrics | Unable to encode the metrics | ERR: ", err)
+		return nil
+	}
 	return b
+}
 
+// ExportMetricsE return the RuntimeSnapshot of the resources used encoded in JSON, or the error of the encoding.
+// The fields of the Monitor are at the first level, the stats of the process and of the host are in "Process" and "Host".
+func ExportMetricsE() ([]byte, error) {
+	return json.Marshal(ReadRuntimeSnapshot())
 }
 
 /* func ExportMetrics() {
@@ -165,47 +70,30 @@ func ExportMetrics() []byte {
 	log.Debug(memstats)
 }
 */
-// printMemUsage outputs the current, total and OS memory being used.
-// As well as the number of garage collection cycles completed.
-func printMemUsage(m *runtime.MemStats, g *debug.GCStats) string {
-	runtime.ReadMemStats(m)
-	debug.ReadGCStats(g)
-	// For info eon each, see: https://golang.org/pkg/runtime/#MemStats
-	return "Alloc = " + bToMb(m.Alloc) + "MiB\tTotalAlloc = " + bToMb(m.TotalAlloc) +
-		"MiB\tSys = " + bToMb(m.Sys) + "MiB\tNumGC = " + strconv.FormatInt(int64(m.NumGC), 10) + "\tPausGCTotal = " + g.PauseTotal.String()
-}
-
-// lazy
+// bToMb convert the bytes in MiB
 func bToMb(b uint64) string {
-	return strconv.FormatUint(b/2024, 10)
+	return strconv.FormatUint(b/(1024*1024), 10)
 }
 
 // ReadFile is in charge to read the last number of lines for a given file and return the content in a compressed format
 func ReadFile(filename string, lines int) []byte {
-	app := "tail -n " + strconv.Itoa(lines) + " \"" + filename + "\""
-	cmd := exec.Command("/bin/sh", "-c", app)
-	stdout, err := cmd.Output()
-	if len(stdout) == 0 || err != nil { // Empty file or error
+	data, err := TailFile(filename, lines)
+	if err != nil {
+		log.Error("ReadFile | Unable to read file [", filename, "] | ERR: ", err)
+		return nil
+	}
+	if len(data) == 0 { // Empty file
 		log.Error("ReadFile | ERROR, empty file | ", filename)
 		return nil
 	}
-	return gozstd.Compress(nil, stdout)
+	return zstdCompress(data)
 }
 
 // ReadFilePath is delegated to filter every (sub)file path from a given directory
 func ReadFilePath(path string) []string {
-	fileList := []string{}
 	// Read all the file recursivly
 	log.Debug("ReadFilePath | Reading file in ", path)
-	err := filepath.Walk(path, func(file string, f os.FileInfo, err error) error {
-		if IsFile(file) {
-			log.Trace("ReadFilePath | Adding file -> ", file)
-			fileList = append(fileList, file)
-		} else {
-			log.Debug("ReadFilePath | Directory found, skipping! -> ", file)
-		}
-		return nil
-	})
+	fileList, err := ReadFilePathE(path)
 	if err != nil {
 		log.Error("ReadFilePath | Some trouble | Path: ", path, " | ERR: ", err)
 		return nil
@@ -219,172 +107,126 @@ func FilterFromFileCompress(filename string, maxLinesToSearch int, toFilter stri
 	log.Trace("FilterFromFileCompress | START")
 	stdout := FilterFromFile(filename, maxLinesToSearch, toFilter, reverse)
 	log.Debug("FilterFromFileCompress | STOP | Ok, compressing the data ...", stdout)
-	return gozstd.Compress(nil, []byte(stdout))
+	return zstdCompress([]byte(stdout))
 }
 
-// FilterFromFile return the text that containt "toFilter" from the file "filename" in a zipped format
-// TODO: Write the method in C using FSEEK instead of line
+// FilterFromFile return the text that containt "toFilter" from the file "filename".
+// The filter is a case insensitive regular expression, if reverse is true the matching lines are discarded.
 func FilterFromFile(filename string, maxLinesToSearch int, toFilter string, reverse bool) string {
 	log.Trace("FilterFromFile | START")
-
-	toFilter = strings.Replace(toFilter, "\"", "\\\"", -1) // Replace the ' " ' in a "grep compliant" format
-
-	app := "tail -n " + strconv.Itoa(maxLinesToSearch) + "  " + filename + "|egrep -i \"" + toFilter + "\""
-	if reverse { // if the reverse enable add -v for the reverse search
-		app += " -v"
+	filter, err := NewLineFilter(toFilter, reverse)
+	if err != nil {
+		log.Error("FilterFromFile | Invalid filter [", toFilter, "] | ERR: ", err)
+		return ""
 	}
-
-	cmd := exec.Command("/bin/sh", "-c", app)
-	stdout, _ := cmd.Output()
-	return string(stdout)
+	data, err := TailFileFilter(filename, maxLinesToSearch, filter)
+	if err != nil {
+		log.Error("FilterFromFile | Unable to read file [", filename, "] | ERR: ", err)
+		return ""
+	}
+	return string(data)
 }
 
 // GetFileModification return the last modification time of the file in input in a UNIX time format
 func GetFileModification(filepath string) int64 {
-	f, err := os.Open(filepath)
+	modTime, err := GetFileModificationE(filepath)
 	if err != nil {
 		log.Error("GetFileModification | Error on [", filepath, "] | Err: ", err)
 		return -1
 	}
-	defer f.Close()
-	statinfo, err := f.Stat()
-	if err != nil {
-		log.Error("GetFileModification | Error getting stats of file -> :/ ", filepath, " | ERR: ", err)
-		return -1
-	}
-	return statinfo.ModTime().Unix()
+	return modTime
 }
 
 // GetFileDate is delegated to return the date in a string format in which the file was (latest) modified
 func GetFileDate(filepath string) string {
-	unixTimestamp := GetFileModification(filepath)
-	if unixTimestamp != -1 {
-		log.Debug("GetFileDate | Timestamp [", unixTimestamp, "] retrieved! | Going to convert to human date format ...")
-		loc, err := time.LoadLocation("Europe/Rome")
-		if err != nil {
-			log.Fatal("GetFileDate | Error reading modification time in file [", filepath, "]")
-			return ""
-		}
-		currentTime := time.Unix(unixTimestamp, 0).In(loc)
-		date := currentTime.Format("2006-01-02 15:04:05")
-		log.Debug("GetFileDate | Date converted!! -> ", date)
-		return date
+	date, err := GetFileDateE(filepath)
+	if err != nil {
+		log.Error("GetFileDate | Error while reading the file [", filepath, "] | ERR: ", err)
+		return ""
 	}
-	log.Error("GetFileDate | Error while reading the file :/")
-
-	return ""
-
+	log.Debug("GetFileDate | Date converted!! -> ", date)
+	return date
 }
 
-// CountLine return the number of line for a given file using the "wc" shell utils (CPU hungry)
+// CountLine return the number of line for a given file (like the "wc -l" shell utils)
 func CountLine(filename string) int {
-	app := "wc -l \"" + filename + "\""
-	cmd := exec.Command("/bin/sh", "-c", app)
-	stdout, err := cmd.Output()
+	n, err := CountFileLines(filename)
 	if err != nil { // File deleted ?
 		log.Error("CountLine | Error retrieving number of lines of file [", filename, "] | ERR: ", err)
-		log.Error("STDOOUT: " + string(stdout))
 		return -1
 	}
-	n, err := strconv.Atoi(strings.Split(string(stdout), " ")[0]) //Extract files number
-	if err != nil {
-		log.Error("CountLine | Error casting string to number [", filename, "] | ERR: ", err)
-		return -1
-	}
-	return n
+	return int(n)
 }
 
 // ValidateInjection provide commons methods for validate a given payload
+// NOTE: use a PathValidator for the paths that have to be resolved in a root directory, or PayloadRules for custom checks
 func ValidateInjection(payload string, mustContain []string) bool {
 	if len(payload) <= 4 {
 		log.Debug("ValidateInjection | payload empty")
 		return false
 	}
-	// Verify if the payload contains one of the list of string that we assume that have to have
-	if mustContain != nil {
-		log.Debug("ValidateInjection | verify word that must be contained")
-
-		checkContains := false
-		for i := 0; i < len(mustContain); i++ {
-			if strings.Contains(payload, mustContain[i]) {
-				log.Debug("ValidateInjection | Payload [", payload, "] contains [", mustContain[i], "]")
-				// Ok, check satisfied, set a flag and exit the iteration
-				checkContains = true
-				break
-			}
-		}
-		// If the flag is not true, no party
-		if !checkContains {
-			log.Error("ValidateInjection | Payload [", payload, "] does not contains any of our validation input [", mustContain, "]")
-			return false
-		}
+	rules := PayloadRules{MustContain: mustContain}
+	if err := rules.Validate(payload); err != nil {
+		log.Error("ValidateInjection | Payload [", payload, "] does not contains any of our validation input [", mustContain, "]")
+		return false
 	}
-	evilword := [...]string{"../", "..", "/./", "/etc/", "/bin/", "/usr/", "/var/"}
-
 	log.Debug("ValidateInjection | Trying to find evil word [", payload, "] ...")
-	// Verify if the payload contains one of the evilword
-	for i := 0; i < len(evilword); i++ {
-		if strings.Contains(payload, evilword[i]) {
-			log.Error("ValidateInjection | EvilWord [", payload, "] -- [", evilword[i], "]")
-			return false
-		}
+	if err := checkInjectionPath(payload); err != nil {
+		log.Error("ValidateInjection | EvilWord [", payload, "] | ERR: ", err)
+		return false
 	}
 	return true
 }
 
 // Lz4CompressData is delegated to compress the input string data using the lz4 algorithm.
-// It return the compressed data itself and the lenght of the compressed data.
+// It return the compressed data itself (a LZ4 block without header) and the lenght of the compressed data.
+// NOTE: use Lz4CompressBlock for a block that contains the size of the original data.
 func Lz4CompressData(fileContent string) ([]byte, int) {
-	toCompress := []byte(fileContent)
-	compressed := make([]byte, len(toCompress))
-
-	//compress
-	var ht [1 << 16]int
-	lenght, err := lz4.CompressBlock(toCompress, compressed, ht[:])
+	compressed, err := lz4CompressRawBlock([]byte(fileContent), 0)
 	if err != nil {
-		panic(err)
+		log.Error("Lz4CompressData | Unable to compress data | ERR: ", err)
+		return nil, 0
 	}
-	log.Warning("compressed Data:", string(compressed[:lenght]))
-	return compressed, lenght
+	return compressed, len(compressed)
 }
 
-// Lz4DecompressData is delegated to extract the previously compressed data
-func Lz4DecompressData(compressedData []byte, l int) {
-	//decompress
-	decompressed := make([]byte, len(compressedData)*3)
-	lenght, err := lz4.UncompressBlock(compressedData[:l], decompressed)
-	if err != nil {
-		panic(err)
+// Lz4DecompressData is delegated to extract the data previously compressed with Lz4CompressData.
+// l is the lenght of the compressed data. The output buffer grow until the decompressed data fit in it.
+func Lz4DecompressData(compressedData []byte, l int) ([]byte, error) {
+	if l < 0 || l > len(compressedData) {
+		return nil, ErrLz4Corrupted
 	}
-	log.Warning("\ndecompressed Data:", string(decompressed[:lenght]))
+	return lz4DecompressRawBlock(compressedData[:l], 0)
 }
 
 //IsFile verify if a give filepath is a directory
 func IsFile(path string) bool {
-	
-	fi, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		log.Error("IsFile | File ",path,": No such file or directory!")
-        return false
-    }
+	isFile, err := IsFileE(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Error("IsFile | File ", path, ": No such file or directory!")
+		return false
+	}
 	if err != nil {
 		log.Error("IsFile | Fatal on path ", path, " | ERR: ", err)
 		return false
 	}
-	// fi.IsDir()
-	return !fi.Mode().IsDir()
+	return isFile
 }
 
 // IsDir is delegated to verify that the given path is a directory
 func IsDir(path string) bool {
 	log.Debug("IsDir | Verifying if ", path, " is a directory")
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
+	isDir, err := IsDirE(path)
+	if errors.Is(err, os.ErrNotExist) {
 		log.Error("IsDir | Path [", path, "] does not exist")
 		return false
 	}
-	log.Debug("IsDir | Path ", path, " isDir? -> ", info.IsDir())
-	return info.IsDir()
+	if err != nil {
+		log.Error("IsDir | Error on path [", path, "] | ERR: ", err)
+		return false
+	}
+	log.Debug("IsDir | Path ", path, " isDir? -> ", isDir)
+	return isDir
 }
 
 // RemoveFromString Remove a given element from a string
@@ -406,40 +248,24 @@ func SplitStringInArray(data string) []string {
 // ReadAllFileInArray is delegated to read the file content as tokenize the data by the new line
 func ReadAllFileInArray(filePath string) []string {
 	log.Debug("ReadAllFileInArray | Reading file and splitting in lines")
-	data, err := ioutil.ReadFile(filePath)
+	lines, err := ReadAllFileInArrayE(filePath)
 	if err != nil {
 		log.Error("ReadAllFileInArray | Error during reading of file ", filePath, " | Err: ", err)
 		return nil
 	}
-	return strings.Split(string(data), "\n")
+	return lines
 }
 
 // ReadAllFile is delegated to read and return all the content of the given file
 func ReadAllFile(filePath string) string {
-	// Open a file.
-	var file *os.File // File to read
-	var err error
-	var reader *bufio.Reader
-	var content []byte
-	//var result string
 	log.Trace("ReadAllFile | START")
-	file, err = os.Open(filePath)
-	if err != nil {
-		log.Error("ReadAllFile | Error OPENING file [", filePath, "] | Err: ", err)
-		return ""
-	}
-	defer file.Close()
-
-	// Use bufio.NewReader to get a Reader.
-	// ... Then use ioutil.ReadAll to read the entire content.
-	reader = bufio.NewReader(file)
-	content, err = ioutil.ReadAll(reader)
+	content, err := ReadAllFileE(filePath)
 	if err != nil {
 		log.Error("ReadAllFile | Error READING file [", filePath, "] | Err: ", err)
 		return ""
 	}
 	log.Trace("ReadAllFile | STOP")
-	return string(content)
+	return content
 }
 
 // IsASCII is delegated to verify if a given string is ASCII compliant
@@ -513,10 +339,10 @@ func ByteCountIEC(b int64) string {
 }
 
 // RetrieveLines return the number of lines in the given string
+// The last line is counted even if is not terminated by the new line
 func RetrieveLines(fileContet string) int {
-	scanner := bufio.NewScanner(strings.NewReader(fileContet)) // Create a scanner for iterate the string
-	counter := 0
-	for scanner.Scan() {
+	counter := strings.Count(fileContet, "\n")
+	if len(fileContet) > 0 && fileContet[len(fileContet)-1] != '\n' {
 		counter++
 	}
 	return counter
@@ -582,6 +408,7 @@ func JoinArray(data []string) string {
 }
 
 // VerifyIfPresent Verify if a given string is present in the list
+// NOTE: for long lists or big contents use a Matcher, that scan the content only once
 func VerifyIfPresent(content string, entryList []string) bool {
 	for i := 0; i < len(entryList); i++ {
 		if strings.Contains(content, entryList[i]) {
@@ -591,15 +418,6 @@ func VerifyIfPresent(content string, entryList []string) bool {
 	return false
 }
 
-// StartCPUProfiler Save the cpu profile information into the given file
-// NOTE: Remember to defer pprof.StopCPUProfile() after the function
-func StartCPUProfiler(file *os.File) {
-	// Start the cpu profiler
-	if err := pprof.StartCPUProfile(file); err != nil {
-		log.Fatal("Could not start CPU profile: ", err)
-	}
-}
-
 //ExtractString is delegated to filter the content of the given data delimited by 'first' and 'last' string
 func ExtractString(data *string, first, last string) string {
 	// Find the first instance of 'start' in the give string data
@@ -620,30 +438,18 @@ func ExtractString(data *string, first, last string) string {
 
 // RecognizeFormat is delegated to valutate the extension and return the properly Mimetype by a given format type
 // reurn: (Mimetype http compliant,Content-Disposition header value)
+// NOTE: use DetectFileMimeType for recognize the type also from the content of the file
 func RecognizeFormat(input string) (string, string) {
-	// Find the last occurrence of the dot
-	// extract only the extension of the file by slicing the string
-	var contentDisposition string
-	var mimeType string
-	contentDisposition = `inline; filename="` + input + `"`
-	switch input[strings.LastIndex(input, ".")+1:] {
-	case "doc":
-		mimeType = "application/msword"
-		//mimeType = "application/octet-stream"
-	case "docx":
-		mimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
-		//mimeType = "application/octet-stream"
-	case "pdf":
-		mimeType = "application/pdf"
-		//contentDisposition = `inline; filename="` + input + `"`
-	default:
-		mimeType = "application/octet-stream"
-		contentDisposition = `inline; filename="` + input + `"`
-	}
-	return mimeType, contentDisposition
-}
-
-// VerifyCert is delegated to verify that the given public and private cert exist in the filepath
+	mimeType, ok := MimeTypeByExtension(input)
+	if !ok {
+		mimeType = DefaultMimeType
+	}
+	return mimeType, ContentDisposition(DispositionInline, input)
+}
+
+// VerifyCert is delegated to verify that the given public and private cert exist in the filepath,
+// that the private key match the certificate and that the certificate is not expired.
+// NOTE: use VerifyCertFiles for verify also the chain of the certificate
 func VerifyCert(filePath, pub, priv string) bool {
 	log.Debug("VerifyCert | Pub: ", pub, " | Priv: ", priv, " | Path: ", filePath)
 	if IsDir(filePath) {
@@ -655,6 +461,15 @@ func VerifyCert(filePath, pub, priv string) bool {
 			log.Error("VerifyCert | Priv [", path.Join(filePath, priv), "] does not exist ...")
 			return false
 		}
+		info, err := InspectCertPair(path.Join(filePath, pub), path.Join(filePath, priv))
+		if err != nil {
+			log.Error("VerifyCert | Invalid certificate | ERR: ", err)
+			return false
+		}
+		if now := time.Now(); now.Before(info.NotBefore) || now.After(info.NotAfter) {
+			log.Error("VerifyCert | Certificate [", info.Subject, "] not valid now, valid from ", info.NotBefore, " to ", info.NotAfter)
+			return false
+		}
 		return true
 	}
 	log.Error("VerifyCert | SSL Directory [", filePath, "] does not exist ...")
@@ -679,17 +494,14 @@ func VerifyFilesExists(filePath string, files []string) bool {
 }
 
 // SecureRequest is delegate to set the necessary secure headers
-// NOTE: CORS is set to '*', be sure to rewrite the headers when expose the application
+// NOTE: CORS is set to '*', be sure to rewrite the headers when expose the application.
+// Use a SecurityPolicy (or SecurityMiddleware) for configure the headers.
 func SecureRequest(ctx *fasthttp.RequestCtx, ssl bool) {
-	ctx.Response.Header.Set("X-Frame-Options", "DENY")
-	ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
-	ctx.Response.Header.Set("X-XSS-Protection", "1; mode=block")
-	ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
 	if ssl {
-		ctx.Response.Header.Set("Content-Security-Policy", "upgrade-insecure-requests")
-		ctx.Response.Header.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		legacySSLSecurityPolicy.Apply(ctx, true)
+	} else {
+		legacySecurityPolicy.Apply(ctx, false)
 	}
-
 }
 
 // CreateJSON is delegated to create a json object for the key pair in input
@@ -0,0 +1,373 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// bkTreeVersion is the version of the serialization format of the BKTree
+const bkTreeVersion = 1
+
+// bkEdge link a node to the child at the given distance
+type bkEdge struct {
+	Distance int32
+	Node     int32
+}
+
+// bkNode is a word of the BKTree
+type bkNode struct {
+	word     string
+	runes    []rune
+	children []bkEdge
+}
+
+// child return the index of the child at the given distance, -1 if not present
+func (n *bkNode) child(distance int) int {
+	for _, edge := range n.children {
+		if int(edge.Distance) == distance {
+			return int(edge.Node)
+		}
+	}
+	return -1
+}
+
+// BKTree is an index of words for the fuzzy lookup using the Levenshtein distance.
+// Thanks to the triangle inequality only a small part of the dictionary is compared with the word to search.
+// The BKTree is safe for concurrent use.
+// NOTE: the BKTree use little memory and can be modified, but the lookup is slower than the SymSpell one: with 100k words
+// a search take some milliseconds at distance 2 (see BenchmarkFuzzySearch). Use a SymSpell for the sub-millisecond lookups.
+type BKTree struct {
+	mu    sync.RWMutex
+	nodes []bkNode
+}
+
+// NewBKTree create the index of the given words
+func NewBKTree(words ...string) *BKTree {
+	t := &BKTree{}
+	t.Add(words...)
+	return t
+}
+
+// NewBKTreeFromReader create the index of the words read from the given reader, one word per line (the format used by SpellCheck)
+func NewBKTreeFromReader(r io.Reader) (*BKTree, error) {
+	t := &BKTree{}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		t.Add(line)
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewBKTreeFromFile create the index of the words of the given dictionary file, one word per line
+func NewBKTreeFromFile(filename string) (*BKTree, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, newFileError("open", filename, err)
+	}
+	defer file.Close()
+	return NewBKTreeFromReader(file)
+}
+
+// Index create the BKTree of the words of the SpellChecker dictionary
+func (s *SpellChecker) Index() *BKTree {
+	t := &BKTree{}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.entries {
+		t.add(s.entries[i].word)
+	}
+	return t
+}
+
+// Add insert the given words in the index, the empty and the duplicated words are ignored
+func (t *BKTree) Add(words ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			t.add(word)
+		}
+	}
+}
+
+// add insert the word in the tree, the lock must be held
+func (t *BKTree) add(word string) {
+	runes := []rune(word)
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, bkNode{word: word, runes: runes})
+		return
+	}
+	cache := make([]int, len(runes)+1)
+	for i := 0; ; {
+		node := &t.nodes[i]
+		distance := levenshteinBounded(node.runes, runes, len(node.runes)+len(runes), cache)
+		if distance == 0 { // Already present
+			return
+		}
+		if i = node.child(distance); i == -1 {
+			node.children = append(node.children, bkEdge{Distance: int32(distance), Node: int32(len(t.nodes))})
+			t.nodes = append(t.nodes, bkNode{word: word, runes: runes})
+			return
+		}
+	}
+}
+
+// Len return the number of words in the index
+func (t *BKTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.nodes)
+}
+
+// walk visit the nodes within the radius returned by visit, starting from the given one.
+// visit is called with the nodes that have a distance not greater than the current radius.
+func (t *BKTree) walk(word string, radius int, visit func(node *bkNode, distance int) int) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	pattern := newMyersPattern([]rune(word))
+	stack := []int32{0}
+	for len(stack) > 0 {
+		node := &t.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		distance := pattern.distance(node.runes)
+		if distance <= radius {
+			radius = visit(node, distance)
+		}
+		// Only the children in [distance-radius, distance+radius] can contain a word within the radius
+		for _, edge := range node.children {
+			if d := int(edge.Distance); d >= distance-radius && d <= distance+radius {
+				stack = append(stack, edge.Node)
+			}
+		}
+	}
+}
+
+// Search return all the words with a distance not greater than maxDistance from the given one, sorted by distance and word
+func (t *BKTree) Search(word string, maxDistance int) []Suggestion {
+	var suggestions []Suggestion
+	t.mu.RLock()
+	t.walk(word, maxDistance, func(node *bkNode, distance int) int {
+		suggestions = append(suggestions, Suggestion{Word: node.word, Distance: float64(distance)})
+		return maxDistance
+	})
+	t.mu.RUnlock()
+	sortSuggestions(suggestions)
+	return suggestions
+}
+
+// Best return the k words nearest to the given one, with a distance not greater than maxDistance.
+// The search radius shrink as soon as k words are found.
+func (t *BKTree) Best(word string, k, maxDistance int) []Suggestion {
+	if k <= 0 {
+		return t.Search(word, maxDistance)
+	}
+	suggestions := make([]Suggestion, 0, k+1)
+	t.mu.RLock()
+	t.walk(word, maxDistance, func(node *bkNode, distance int) int {
+		if len(suggestions) == k && !lessSuggestion(Suggestion{Word: node.word, Distance: float64(distance)}, suggestions[k-1]) {
+			return int(suggestions[k-1].Distance)
+		}
+		suggestions = append(suggestions, Suggestion{Word: node.word, Distance: float64(distance)})
+		sortSuggestions(suggestions)
+		if len(suggestions) > k {
+			suggestions = suggestions[:k]
+		}
+		if len(suggestions) == k {
+			return int(suggestions[k-1].Distance)
+		}
+		return maxDistance
+	})
+	t.mu.RUnlock()
+	return suggestions
+}
+
+// lessSuggestion order the suggestions by distance and word
+func lessSuggestion(a, b Suggestion) bool {
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+	return a.Word < b.Word
+}
+
+// sortSuggestions sort the suggestions by distance and word
+func sortSuggestions(suggestions []Suggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		return lessSuggestion(suggestions[i], suggestions[j])
+	})
+}
+
+// bkTreeFile is the serialized format of the BKTree
+type bkTreeFile struct {
+	Version  int
+	Words    []string
+	Children [][]bkEdge
+}
+
+// WriteTo serialize the index, so that can be loaded with ReadBKTree without compute the distances again
+func (t *BKTree) WriteTo(w io.Writer) (int64, error) {
+	t.mu.RLock()
+	data := bkTreeFile{Version: bkTreeVersion, Words: make([]string, len(t.nodes)), Children: make([][]bkEdge, len(t.nodes))}
+	for i := range t.nodes {
+		data.Words[i] = t.nodes[i].word
+		data.Children[i] = t.nodes[i].children
+	}
+	t.mu.RUnlock()
+	counter := &countWriter{w: w}
+	err := gob.NewEncoder(counter).Encode(&data)
+	return counter.n, err
+}
+
+// ReadBKTree load an index previously serialized with WriteTo
+func ReadBKTree(r io.Reader) (*BKTree, error) {
+	var data bkTreeFile
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Version != bkTreeVersion {
+		return nil, errors.New("ReadBKTree: unsupported index version")
+	}
+	if len(data.Words) != len(data.Children) {
+		return nil, errors.New("ReadBKTree: corrupted index")
+	}
+	t := &BKTree{nodes: make([]bkNode, len(data.Words))}
+	// The nodes are appended after their parent, so a valid edge always point forward and every node (except the root)
+	// has exactly one parent: a corrupted index can not contain a cycle that make the search loop forever
+	hasParent := make([]bool, len(data.Words))
+	for i, word := range data.Words {
+		for _, edge := range data.Children[i] {
+			if int(edge.Node) <= i || int(edge.Node) >= len(data.Words) || hasParent[edge.Node] {
+				return nil, errors.New("ReadBKTree: corrupted index")
+			}
+			hasParent[edge.Node] = true
+		}
+		t.nodes[i] = bkNode{word: word, runes: []rune(word), children: data.Children[i]}
+	}
+	for i := 1; i < len(hasParent); i++ {
+		if !hasParent[i] {
+			return nil, errors.New("ReadBKTree: corrupted index")
+		}
+	}
+	return t, nil
+}
+
+// SaveBKTree serialize the index in the given file
+func SaveBKTree(t *BKTree, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return newFileError("create", filename, err)
+	}
+	writer := bufio.NewWriter(file)
+	if _, err = t.WriteTo(writer); err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newFileError("write", filename, err)
+	}
+	return nil
+}
+
+// LoadBKTree load the index from a file created by SaveBKTree
+func LoadBKTree(filename string) (*BKTree, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, newFileError("open", filename, err)
+	}
+	defer file.Close()
+	t, err := ReadBKTree(bufio.NewReader(file))
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	return t, nil
+}
+
+// countWriter count the byte written in the underlying writer
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// myersPattern is the precomputed bitmask of a word (up to 64 runes) used by the bit-parallel Levenshtein algorithm of Myers/Hyyrö.
+// A comparison cost a few operations for every rune of the other word, instead of a row of the dynamic programming matrix.
+type myersPattern struct {
+	runes []rune
+	last  uint64
+	ascii [utf8.RuneSelf]uint64
+	other map[rune]uint64
+}
+
+// newMyersPattern compute the bitmask of every rune of the given word
+func newMyersPattern(runes []rune) *myersPattern {
+	p := &myersPattern{runes: runes}
+	if len(runes) == 0 || len(runes) > 64 {
+		return p
+	}
+	p.last = 1 << uint(len(runes)-1)
+	for i, r := range runes {
+		if r < utf8.RuneSelf {
+			p.ascii[r] |= 1 << uint(i)
+		} else {
+			if p.other == nil {
+				p.other = make(map[rune]uint64)
+			}
+			p.other[r] |= 1 << uint(i)
+		}
+	}
+	return p
+}
+
+// distance return the Levenshtein distance between the pattern and the given word
+func (p *myersPattern) distance(text []rune) int {
+	if len(p.runes) == 0 {
+		return len(text)
+	}
+	if p.last == 0 { // Too long for the bit-parallel version
+		return levenshteinBounded(text, p.runes, len(text)+len(p.runes), make([]int, len(p.runes)+1))
+	}
+	pv, mv := ^uint64(0), uint64(0)
+	score := len(p.runes)
+	for _, r := range text {
+		var eq uint64
+		if r >= 0 && r < utf8.RuneSelf {
+			eq = p.ascii[r]
+		} else {
+			eq = p.other[r]
+		}
+		xv := eq | mv
+		xh := (((eq & pv) + pv) ^ pv) | eq
+		ph := mv | ^(xh | pv)
+		mh := pv & xh
+		if ph&p.last != 0 {
+			score++
+		} else if mh&p.last != 0 {
+			score--
+		}
+		ph = ph<<1 | 1
+		mh <<= 1
+		pv = mh | ^(xv | ph)
+		mv = ph & xv
+	}
+	return score
+}
@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Algorithms of the keys generated by GenerateCA and GenerateCert
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// Names of the files written by GenerateDevCerts, use them with VerifyCert(dir, DevCertFile, DevKeyFile)
+const (
+	DevCACertFile = "ca.pem"
+	DevCAKeyFile  = "ca.key"
+	DevCertFile   = "cert.pem"
+	DevKeyFile    = "key.pem"
+)
+
+const (
+	// DefaultCAValidity is the validity of the CA generated when not specified
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+	// DefaultCertValidity is the validity of the certificates generated when not specified
+	DefaultCertValidity = 365 * 24 * time.Hour
+	// defaultRSABits is the size of the RSA keys generated when not specified
+	defaultRSABits = 2048
+)
+
+// ErrUnknownKeyType is returned for an unsupported key algorithm
+var ErrUnknownKeyType = errors.New("unknown key type")
+
+// CertRequest contains the parameters of a certificate to generate
+type CertRequest struct {
+	CommonName   string
+	Organization string
+	// Hosts are the Subject Alternative Names: the IP addresses are recognized, the others are DNS names
+	Hosts []string
+	// KeyType is KeyTypeRSA, KeyTypeECDSA (P-256) or KeyTypeEd25519. Empty for ECDSA.
+	KeyType string
+	// RSABits is the size of the RSA keys, 0 for 2048
+	RSABits int
+	// Validity is the duration of the certificate, 0 for the default (DefaultCAValidity or DefaultCertValidity)
+	Validity time.Duration
+}
+
+// generateKey generate a private key of the given type
+func generateKey(keyType string, rsaBits int) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		if rsaBits <= 0 {
+			rsaBits = defaultRSABits
+		}
+		return rsa.GenerateKey(rand.Reader, rsaBits)
+	case KeyTypeECDSA, "":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, ErrUnknownKeyType
+}
+
+// newCertTemplate return the template of the certificate with a random serial number
+func newCertTemplate(req *CertRequest, defaultValidity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	validity := req.Validity
+	if validity <= 0 {
+		validity = defaultValidity
+	}
+	// Tolerate a small clock skew between the machines
+	notBefore := time.Now().Add(-time.Hour)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: req.CommonName},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		BasicConstraintsValid: true,
+	}
+	if req.Organization != "" {
+		template.Subject.Organization = []string{req.Organization}
+	}
+	for _, host := range req.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return template, nil
+}
+
+// encodeCertPair encode the certificate and the private key (PKCS#8) in PEM format
+func encodeCertPair(der []byte, key crypto.Signer) ([]byte, []byte, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// GenerateCA generate a self-signed CA, returning the certificate and the private key in PEM format
+func GenerateCA(req *CertRequest) ([]byte, []byte, error) {
+	key, err := generateKey(req.KeyType, req.RSABits)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := newCertTemplate(req, DefaultCAValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertPair(der, key)
+}
+
+// GenerateCert generate a certificate for a server (and client) signed by the given CA (PEM certificate and key).
+// With a nil CA the certificate is self-signed. The certificate and the private key are returned in PEM format.
+func GenerateCert(req *CertRequest, caCertPEM, caKeyPEM []byte) ([]byte, []byte, error) {
+	key, err := generateKey(req.KeyType, req.RSABits)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := newCertTemplate(req, DefaultCertValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, isRSA := key.(*rsa.PrivateKey); isRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+	parent, signer := template, crypto.Signer(key)
+	if caCertPEM != nil {
+		chain, err := parseCertificates(caCertPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		caKey, err := parsePrivateKey(caKeyPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		var ok bool
+		if signer, ok = caKey.(crypto.Signer); !ok {
+			return nil, nil, ErrUnknownKeyType
+		}
+		parent = chain[0]
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertPair(der, key)
+}
+
+// WriteCertPair write the PEM certificate and private key in the given directory, the key is readable only by the owner
+func WriteCertPair(dir, certName, keyName string, certPEM, keyPEM []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return newFileError("mkdir", dir, err)
+	}
+	certFile, keyFile := filepath.Join(dir, certName), filepath.Join(dir, keyName)
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		return newFileError("write", certFile, err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return newFileError("write", keyFile, err)
+	}
+	return nil
+}
+
+// DevCertOptions contains the parameters of GenerateDevCerts
+type DevCertOptions struct {
+	// Hosts are the names of the certificate, "localhost", "127.0.0.1" and "::1" if empty
+	Hosts []string
+	// KeyType is the algorithm of the keys, empty for ECDSA
+	KeyType string
+	// Validity of the certificate and of the CA, 0 for the defaults
+	Validity   time.Duration
+	CAValidity time.Duration
+	// Organization is the organization of the certificates
+	Organization string
+	// NewCA regenerate the CA also if already present in the directory
+	NewCA bool
+}
+
+// GenerateDevCerts create a local CA (reused if already present in the directory) and a certificate signed by it,
+// for the development HTTPS servers. The files are written in dir with the names DevCACertFile, DevCAKeyFile, DevCertFile
+// and DevKeyFile, so that VerifyCert(dir, DevCertFile, DevKeyFile) succeed. opts can be nil.
+// The CA (DevCACertFile) must be trusted by the clients.
+func GenerateDevCerts(dir string, opts *DevCertOptions) error {
+	if opts == nil {
+		opts = &DevCertOptions{}
+	}
+	hosts := opts.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	organization := opts.Organization
+	if organization == "" {
+		organization = "GoUtils development"
+	}
+
+	caCertFile, caKeyFile := filepath.Join(dir, DevCACertFile), filepath.Join(dir, DevCAKeyFile)
+	caCertPEM, certErr := ioutil.ReadFile(caCertFile)
+	caKeyPEM, keyErr := ioutil.ReadFile(caKeyFile)
+	if opts.NewCA || certErr != nil || keyErr != nil {
+		var err error
+		caRequest := &CertRequest{CommonName: organization + " CA", Organization: organization, KeyType: opts.KeyType, Validity: opts.CAValidity}
+		if caCertPEM, caKeyPEM, err = GenerateCA(caRequest); err != nil {
+			return err
+		}
+		if err = WriteCertPair(dir, DevCACertFile, DevCAKeyFile, caCertPEM, caKeyPEM); err != nil {
+			return err
+		}
+		log.Info("GenerateDevCerts | Created CA [", caCertFile, "]")
+	}
+
+	request := &CertRequest{CommonName: hosts[0], Organization: organization, Hosts: hosts, KeyType: opts.KeyType, Validity: opts.Validity}
+	certPEM, keyPEM, err := GenerateCert(request, caCertPEM, caKeyPEM)
+	if err != nil {
+		return err
+	}
+	// The CA is appended to the certificate, so that the clients receive the whole chain
+	if err = WriteCertPair(dir, DevCertFile, DevKeyFile, append(certPEM, caCertPEM...), keyPEM); err != nil {
+		return err
+	}
+	log.Info("GenerateDevCerts | Created certificate [", filepath.Join(dir, DevCertFile), "] for ", hosts)
+	return nil
+}
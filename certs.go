@@ -0,0 +1,399 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrCertNoPEM is returned when the file does not contain a PEM block of the expected type
+	ErrCertNoPEM = errors.New("no PEM data found")
+	// ErrCertKeyMismatch is returned when the private key does not match the public key of the certificate
+	ErrCertKeyMismatch = errors.New("private key does not match the certificate")
+	// ErrCertExpired is returned when the certificate is expired
+	ErrCertExpired = errors.New("certificate expired")
+	// ErrCertNotYetValid is returned when the certificate is not valid yet
+	ErrCertNotYetValid = errors.New("certificate not valid yet")
+	// ErrCertExpiring is returned when the certificate expire before the MinValidity of the CertVerifyOptions
+	ErrCertExpiring = errors.New("certificate expiring")
+)
+
+// DefaultCertCheckInterval is the interval between two checks of the files of the CertReloader
+const DefaultCertCheckInterval = 30 * time.Second
+
+// CertInfo contains the details of a certificate and of the chain of its PEM file
+type CertInfo struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	// DNSNames, IPAddresses, EmailAddresses and URIs are the Subject Alternative Names of the certificate
+	DNSNames       []string
+	IPAddresses    []string
+	EmailAddresses []string
+	URIs           []string
+	IsCA           bool
+	// KeyAlgorithm is the algorithm of the public key: RSA, ECDSA or Ed25519
+	KeyAlgorithm string
+	// Fingerprint is the SHA-256 of the certificate, in hexadecimal
+	Fingerprint string
+	// Chain contains the certificates of the PEM file, the first one is the certificate described by the CertInfo
+	Chain []*x509.Certificate
+}
+
+// SANs return all the Subject Alternative Names of the certificate
+func (c *CertInfo) SANs() []string {
+	sans := make([]string, 0, len(c.DNSNames)+len(c.IPAddresses)+len(c.EmailAddresses)+len(c.URIs))
+	sans = append(sans, c.DNSNames...)
+	sans = append(sans, c.IPAddresses...)
+	sans = append(sans, c.EmailAddresses...)
+	return append(sans, c.URIs...)
+}
+
+// ExpiresIn return the time until the expiration of the certificate, negative if already expired
+func (c *CertInfo) ExpiresIn() time.Duration {
+	return time.Until(c.NotAfter)
+}
+
+// newCertInfo extract the details of the first certificate of the chain
+func newCertInfo(chain []*x509.Certificate) *CertInfo {
+	cert := chain[0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := &CertInfo{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IsCA:           cert.IsCA,
+		KeyAlgorithm:   cert.PublicKeyAlgorithm.String(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+		Chain:          chain,
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
+
+// parseCertificates parse all the CERTIFICATE blocks of the PEM data
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, ErrCertNoPEM
+	}
+	return chain, nil
+}
+
+// ParseCertInfo parse the PEM certificate (the first one, followed by the optional intermediate certificates)
+func ParseCertInfo(data []byte) (*CertInfo, error) {
+	chain, err := parseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	return newCertInfo(chain), nil
+}
+
+// LoadCertInfo parse the given PEM certificate file
+func LoadCertInfo(certFile string) (*CertInfo, error) {
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, newFileError("read", certFile, err)
+	}
+	info, err := ParseCertInfo(data)
+	if err != nil {
+		return nil, newFileError("parse", certFile, err)
+	}
+	return info, nil
+}
+
+// parsePrivateKey parse a PEM private key in PKCS#1, PKCS#8 or EC format
+func parsePrivateKey(data []byte) (interface{}, error) {
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return nil, ErrCertNoPEM
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			return x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+	}
+}
+
+// publicKeyOf return the public key of a private key
+func publicKeyOf(key interface{}) crypto.PublicKey {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return nil
+}
+
+// InspectCertPair parse the certificate and the private key, verifying that the key match the certificate
+func InspectCertPair(certFile, keyFile string) (*CertInfo, error) {
+	info, err := LoadCertInfo(certFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, newFileError("read", keyFile, err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, newFileError("parse", keyFile, err)
+	}
+	// The keys are compared in the PKIX encoding, the Equal methods of the keys are not available before go1.15
+	public := publicKeyOf(key)
+	if public == nil {
+		return nil, newFileError("verify", keyFile, ErrCertKeyMismatch)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, newFileError("verify", keyFile, err)
+	}
+	certDER, err := x509.MarshalPKIXPublicKey(info.Chain[0].PublicKey)
+	if err != nil || !bytes.Equal(keyDER, certDER) {
+		return nil, newFileError("verify", keyFile, ErrCertKeyMismatch)
+	}
+	return info, nil
+}
+
+// LoadCABundle load the PEM certificates of the given file in a pool, for verify the chains
+func LoadCABundle(filename string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, newFileError("parse", filename, ErrCertNoPEM)
+	}
+	return pool, nil
+}
+
+// CertVerifyOptions contains the parameters of the verification of a certificate
+type CertVerifyOptions struct {
+	// Roots are the trusted CA, nil for the CA of the system
+	Roots *x509.CertPool
+	// DNSName is the host name that the certificate must be valid for, empty for skip the check
+	DNSName string
+	// MinValidity is the minimum time before the expiration, ex: 30 days for renew the certificates in time
+	MinValidity time.Duration
+	// Now is the time of the verification, zero for the current time
+	Now time.Time
+}
+
+// Verify check the validity dates of the certificate and its chain up to a trusted CA.
+// The intermediate certificates are taken from the PEM file of the certificate.
+func (c *CertInfo) Verify(opts *CertVerifyOptions) error {
+	if opts == nil {
+		opts = &CertVerifyOptions{}
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if now.Before(c.NotBefore) {
+		return fmt.Errorf("%w: valid from %s", ErrCertNotYetValid, c.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(c.NotAfter) {
+		return fmt.Errorf("%w: expired on %s", ErrCertExpired, c.NotAfter.Format(time.RFC3339))
+	}
+	if opts.MinValidity > 0 && c.NotAfter.Sub(now) < opts.MinValidity {
+		return fmt.Errorf("%w: expire on %s", ErrCertExpiring, c.NotAfter.Format(time.RFC3339))
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range c.Chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := c.Chain[0].Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		DNSName:       opts.DNSName,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// VerifyCertFiles inspect the certificate and the key, then verify the certificate with the given options (can be nil)
+func VerifyCertFiles(certFile, keyFile string, opts *CertVerifyOptions) (*CertInfo, error) {
+	info, err := InspectCertPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = info.Verify(opts); err != nil {
+		return info, newFileError("verify", certFile, err)
+	}
+	return info, nil
+}
+
+// CertReloader keep a certificate loaded from the files, reloading it when the files change on disk.
+// It is meant to be used in the GetCertificate of a tls.Config, so that the renewed certificates are used without a restart.
+type CertReloader struct {
+	certFile, keyFile string
+	// CheckInterval is the min interval between two checks of the modification time of the files
+	CheckInterval time.Duration
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	info        *CertInfo
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+// NewCertReloader load the certificate and the key, returning an error if they are not valid
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, CheckInterval: DefaultCertCheckInterval}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// modTimes return the modification time of the certificate and of the key
+func (r *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certStat, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, newFileError("stat", r.certFile, err)
+	}
+	keyStat, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, newFileError("stat", r.keyFile, err)
+	}
+	return certStat.ModTime(), keyStat.ModTime(), nil
+}
+
+// Reload load the certificate from the files. On error the previous certificate is kept.
+func (r *CertReloader) Reload() error {
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	info, err := InspectCertPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return newFileError("load", r.certFile, err)
+	}
+	cert.Leaf = info.Chain[0]
+	r.mu.Lock()
+	r.cert, r.info = &cert, info
+	r.certModTime, r.keyModTime = certModTime, keyModTime
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	log.Info("CertReloader | Loaded certificate [", info.Subject, "] | Expire on: ", info.NotAfter)
+	return nil
+}
+
+// maybeReload reload the certificate if the files changed since the last load, at most once every CheckInterval
+func (r *CertReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < r.CheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	certModTime, keyModTime := r.certModTime, r.keyModTime
+	r.mu.Unlock()
+
+	newCertModTime, newKeyModTime, err := r.modTimes()
+	if err != nil {
+		log.Error("CertReloader | Unable to check the certificate | ERR: ", err)
+		return
+	}
+	if newCertModTime.Equal(certModTime) && newKeyModTime.Equal(keyModTime) {
+		return
+	}
+	// The certificate and the key can be written in different moments, a failure is retried at the next check
+	if err = r.Reload(); err != nil {
+		log.Error("CertReloader | Unable to reload the certificate, keeping the previous one | ERR: ", err)
+	}
+}
+
+// Certificate return the current certificate
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Info return the details of the current certificate
+func (r *CertReloader) Info() *CertInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.info
+}
+
+// GetCertificate implements the GetCertificate of the tls.Config
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// TLSConfig return a tls.Config (TLS 1.2+) that use the certificate of the reloader.
+// For fasthttp, use it with tls.NewListener and Server.Serve.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// NewReloadingTLSConfig return a tls.Config that reload the certificate when the files change
+func NewReloadingTLSConfig(certFile, keyFile string) (*tls.Config, *CertReloader, error) {
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reloader.TLSConfig(), reloader, nil
+}
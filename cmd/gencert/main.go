@@ -0,0 +1,48 @@
+// Command gencert generate a local CA and a certificate signed by it, for the development HTTPS servers.
+//
+//	gencert -dir ssl -hosts localhost,127.0.0.1,myapp.local -key ed25519
+//
+// The files (ca.pem, ca.key, cert.pem, key.pem) are written in the directory in the layout expected by
+// utils.VerifyCert(dir, "cert.pem", "key.pem"). The CA already present in the directory is reused.
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	utils "github.com/alessiosavi/GoUtils"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	dir := flag.String("dir", "ssl", "directory of the generated files")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "comma separated names and IP addresses of the certificate")
+	keyType := flag.String("key", utils.KeyTypeECDSA, "algorithm of the keys: rsa, ecdsa or ed25519")
+	days := flag.Int("days", 365, "validity of the certificate in days")
+	caDays := flag.Int("ca-days", 3650, "validity of the CA in days")
+	organization := flag.String("org", "", "organization of the certificates")
+	newCA := flag.Bool("new-ca", false, "regenerate the CA also if already present")
+	flag.Parse()
+
+	var hostList []string
+	for _, host := range strings.Split(*hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hostList = append(hostList, host)
+		}
+	}
+	opts := &utils.DevCertOptions{
+		Hosts:        hostList,
+		KeyType:      *keyType,
+		Validity:     time.Duration(*days) * 24 * time.Hour,
+		CAValidity:   time.Duration(*caDays) * 24 * time.Hour,
+		Organization: *organization,
+		NewCA:        *newCA,
+	}
+	if err := utils.GenerateDevCerts(*dir, opts); err != nil {
+		log.Fatal("gencert | Unable to generate the certificates | ERR: ", err)
+	}
+	if !utils.VerifyCert(*dir, utils.DevCertFile, utils.DevKeyFile) {
+		log.Fatal("gencert | Generated certificates are not valid")
+	}
+}
@@ -0,0 +1,366 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/pierrec/lz4"
+)
+
+// Name of the compression algorithms supported by NewCompressWriter and NewDecompressReader.
+// The names are the same used in the HTTP Content-Encoding header.
+const (
+	CompressionZstd     = "zstd"
+	CompressionLz4      = "lz4"
+	CompressionGzip     = "gzip"
+	CompressionSnappy   = "snappy"
+	CompressionBrotli   = "br"
+	CompressionIdentity = "identity"
+)
+
+// defaultCompressBlockSize is the size of the independent blocks compressed concurrently
+const defaultCompressBlockSize = 1 << 20
+
+// ErrUnknownCompression is returned for an unsupported compression algorithm
+var ErrUnknownCompression = errors.New("unknown compression algorithm")
+
+// CompressOptions contains the parameters of the compressors
+type CompressOptions struct {
+	// Level is the compression level of the algorithm, 0 for the default level (ignored by snappy)
+	Level int
+	// Concurrency is the number of blocks compressed at the same time, 0 or 1 for a single stream.
+	// Every block is compressed as an independent frame (or gzip member), the concatenation is still a valid stream
+	// for zstd, lz4, gzip and snappy. Brotli streams can not be concatenated, so brotli is always a single stream.
+	Concurrency int
+	// BlockSize is the size of the blocks compressed concurrently, 0 for 1MB
+	BlockSize int
+}
+
+// normalizeCompression return the canonical name of the given algorithm
+func normalizeCompression(algorithm string) string {
+	switch strings.ToLower(strings.TrimSpace(algorithm)) {
+	case "zstd", "zst", "zstandard":
+		return CompressionZstd
+	case "lz4":
+		return CompressionLz4
+	case "gzip", "gz", "x-gzip":
+		return CompressionGzip
+	case "snappy", "sz":
+		return CompressionSnappy
+	case "br", "brotli":
+		return CompressionBrotli
+	case "identity", "none", "":
+		return CompressionIdentity
+	}
+	return ""
+}
+
+// concatenableCompression return true if the concatenation of the streams of the algorithm is a valid stream,
+// that is required for compress the blocks concurrently
+func concatenableCompression(algorithm string) bool {
+	switch normalizeCompression(algorithm) {
+	case CompressionZstd, CompressionLz4, CompressionGzip, CompressionSnappy:
+		return true
+	}
+	return false
+}
+
+// nopWriteCloser add a no-op Close to a writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newSingleCompressWriter return the compressor of the algorithm, without concurrency
+func newSingleCompressWriter(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch normalizeCompression(algorithm) {
+	case CompressionZstd:
+		return newZstdWriter(w, level)
+	case CompressionLz4:
+		writer := lz4.NewWriter(w)
+		writer.Header.CompressionLevel = level
+		return writer, nil
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionSnappy:
+		return snappy.NewBufferedWriter(w), nil
+	case CompressionBrotli:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	case CompressionIdentity:
+		return nopWriteCloser{w}, nil
+	}
+	return nil, ErrUnknownCompression
+}
+
+// NewCompressWriter return a writer that compress the data with the given algorithm and write them in w.
+// The writer must be closed in order to flush the data, w is not closed. opts can be nil.
+func NewCompressWriter(w io.Writer, algorithm string, opts *CompressOptions) (io.WriteCloser, error) {
+	if opts == nil {
+		opts = &CompressOptions{}
+	}
+	if normalizeCompression(algorithm) == "" {
+		return nil, ErrUnknownCompression
+	}
+	if opts.Concurrency <= 1 || !concatenableCompression(algorithm) {
+		return newSingleCompressWriter(w, algorithm, opts.Level)
+	}
+	return newParallelWriter(w, algorithm, opts), nil
+}
+
+// NewDecompressReader return a reader that decompress the data read from r with the given algorithm
+func NewDecompressReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch normalizeCompression(algorithm) {
+	case CompressionZstd:
+		return newZstdReader(r)
+	case CompressionLz4:
+		return ioutil.NopCloser(lz4.NewReader(r)), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionSnappy:
+		return ioutil.NopCloser(snappy.NewReader(r)), nil
+	case CompressionBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case CompressionIdentity:
+		return ioutil.NopCloser(r), nil
+	}
+	return nil, ErrUnknownCompression
+}
+
+// CompressStream compress all the data read from src and write them in dst, returning the number of byte read
+func CompressStream(dst io.Writer, src io.Reader, algorithm string, opts *CompressOptions) (int64, error) {
+	writer, err := NewCompressWriter(dst, algorithm, opts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(writer, src)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// DecompressStream decompress all the data read from src and write them in dst, returning the number of byte written
+func DecompressStream(dst io.Writer, src io.Reader, algorithm string) (int64, error) {
+	reader, err := NewDecompressReader(src, algorithm)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return io.Copy(dst, reader)
+}
+
+// TailFileCompressTo compress the last 'lines' lines of the given file directly in w (ex: an HTTP response),
+// without load the content in memory
+func TailFileCompressTo(w io.Writer, filename string, lines int, algorithm string, opts *CompressOptions) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return newFileError("open", filename, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return newFileError("stat", filename, err)
+	}
+	offset, err := TailOffset(file, info.Size(), lines)
+	if err != nil {
+		return newFileError("read", filename, err)
+	}
+	_, err = CompressStream(w, io.NewSectionReader(file, offset, info.Size()-offset), algorithm, opts)
+	return err
+}
+
+// FilterFromFileCompressTo compress the lines that contain "toFilter" among the last 'maxLinesToSearch' lines of the given file
+// directly in w, like FilterFromFileCompress but without load the content in memory
+func FilterFromFileCompressTo(w io.Writer, filename string, maxLinesToSearch int, toFilter string, reverse bool, algorithm string, opts *CompressOptions) error {
+	filter, err := NewLineFilter(toFilter, reverse)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return newFileError("open", filename, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return newFileError("stat", filename, err)
+	}
+	offset, err := TailOffset(file, info.Size(), maxLinesToSearch)
+	if err != nil {
+		return newFileError("read", filename, err)
+	}
+
+	writer, err := NewCompressWriter(w, algorithm, opts)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, info.Size()-offset))
+	for err == nil {
+		var line []byte
+		if line, err = reader.ReadBytes('\n'); len(line) == 0 {
+			break
+		}
+		if line[len(line)-1] == '\n' {
+			line = line[:len(line)-1]
+		}
+		if filter.Match(line) {
+			if _, werr := writer.Write(append(line, '\n')); werr != nil {
+				err = werr
+			}
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// parallelBlock is a block compressed by a worker of the parallelWriter
+type parallelBlock struct {
+	data []byte
+	done chan struct{}
+	err  error
+}
+
+// parallelWriter compress independent blocks concurrently, writing them in order
+type parallelWriter struct {
+	w         io.Writer
+	algorithm string
+	level     int
+	blockSize int
+	buf       []byte
+	// queue contains the blocks in the order of submission, its capacity limit the blocks compressed at the same time
+	queue  chan *parallelBlock
+	done   chan struct{}
+	mu     sync.Mutex
+	err    error
+	closed bool
+	// submitted is true if at least a block was compressed
+	submitted bool
+}
+
+// newParallelWriter start the goroutine that write the compressed blocks
+func newParallelWriter(w io.Writer, algorithm string, opts *CompressOptions) *parallelWriter {
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = defaultCompressBlockSize
+	}
+	p := &parallelWriter{
+		w:         w,
+		algorithm: algorithm,
+		level:     opts.Level,
+		blockSize: blockSize,
+		queue:     make(chan *parallelBlock, opts.Concurrency),
+		done:      make(chan struct{}),
+	}
+	go p.writeBlocks()
+	return p
+}
+
+// writeBlocks write the compressed blocks in order, as soon as they are ready
+func (p *parallelWriter) writeBlocks() {
+	defer close(p.done)
+	for block := range p.queue {
+		<-block.done
+		err := block.err
+		if err == nil {
+			_, err = p.w.Write(block.data)
+		}
+		if err != nil {
+			p.setErr(err)
+		}
+	}
+}
+
+func (p *parallelWriter) setErr(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+func (p *parallelWriter) getErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// submit start the compression of the given data
+func (p *parallelWriter) submit(data []byte) {
+	p.submitted = true
+	block := &parallelBlock{done: make(chan struct{})}
+	// Block when too many blocks are waiting
+	p.queue <- block
+	go func() {
+		defer close(block.done)
+		var out bytes.Buffer
+		writer, err := newSingleCompressWriter(&out, p.algorithm, p.level)
+		if err == nil {
+			if _, err = writer.Write(data); err == nil {
+				err = writer.Close()
+			}
+		}
+		block.data, block.err = out.Bytes(), err
+	}()
+}
+
+func (p *parallelWriter) Write(data []byte) (int, error) {
+	if p.closed {
+		return 0, errors.New("write on closed compressor")
+	}
+	if err := p.getErr(); err != nil {
+		return 0, err
+	}
+	n := len(data)
+	for len(data) > 0 {
+		free := p.blockSize - len(p.buf)
+		if free > len(data) {
+			free = len(data)
+		}
+		p.buf = append(p.buf, data[:free]...)
+		data = data[free:]
+		if len(p.buf) == p.blockSize {
+			p.submit(p.buf)
+			p.buf = make([]byte, 0, p.blockSize)
+		}
+	}
+	return n, nil
+}
+
+// Close compress the remaining data and wait until all the blocks are written
+func (p *parallelWriter) Close() error {
+	if p.closed {
+		return p.getErr()
+	}
+	p.closed = true
+	// An empty block is submitted also for an empty input, so that the output is a valid stream
+	if len(p.buf) > 0 || !p.submitted {
+		p.submit(p.buf)
+		p.buf = nil
+	}
+	close(p.queue)
+	<-p.done
+	return p.getErr()
+}
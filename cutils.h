@@ -1,24 +1,11 @@
 #ifndef _CUTILS_H
 #define _CUTILS_H
 
-void set_file_size(FILE *fp, int *fsize);
-long get_file_size(char *filename);
-char *read_content(const char *filename);
-void read_content_no_alloc(char *filename, char *out);
-void lowerize_string(char *data);
-char **makeCharArray(int size);
-void setArrayString(char **a, char *s, int n);
-void freeCharArray(char **a, int size);
-void printCharArray(char **a, int size);
-size_t levenshtein_n(const char *a, const size_t length, const char *b,
-                     const size_t bLength);
-int levenshtein(const char *a, const char *b);
-char *apply_levian_to_array(char **a, char *wrong, int size);
-char **split_data(char *str, int lenght);
-char *spell_check(const char *filename, const int file_lines, char *wrong);
-int verify_presence_data_insensitive(char *data, char *to_find);
-int verify_presence_data(const char *data, const char *to_find);
-int verify_presence_filename(const char *filename, const char *to_find);
+#include <stdint.h>
 
+long get_file_size(char *filename);
+int open_file_size(const char *filename, int *fd, int64_t *size);
+int read_fd_content(int fd, char *out, int64_t size, int64_t *nread);
+void close_fd(int fd);
 
 #endif
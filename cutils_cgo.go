@@ -0,0 +1,87 @@
+//go:build cgo
+// +build cgo
+
+package utils
+
+// #cgo CFLAGS: -g -Wall
+// #include <stdio.h>
+// #include <stdlib.h>
+// #include <string.h>
+// #include "cutils.h"
+import "C"
+import (
+	"syscall"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/* ==== C wrappers ==== */
+
+// C methods for speedup the code
+
+// GetFileSizeC wrapper method for retrieve byte lenght of a file
+func GetFileSizeC(filename string) int64 {
+	// Cast a string to a 'C string'
+	fname := C.CString(filename)
+	defer C.free(unsafe.Pointer(fname))
+	// get the file size of the file
+	size := C.get_file_size(fname)
+	return int64(size)
+}
+
+// minReadBufferSize is the initial size of the buffer for the files that report a size of 0 (ex: /proc/*)
+const minReadBufferSize = 512
+
+// ReadFileContentCE read the content of the given file using a single open/fstat/read sequence on the same descriptor.
+// The content is read directly in Go memory until EOF, so there is no limit on the size of the file and nothing to free.
+// The size reported by fstat is only a hint: the special files that report a size of 0 (ex: /proc/*) and the files
+// that grow during the read are read completely, as ioutil.ReadFile.
+func ReadFileContentCE(filename string) ([]byte, error) {
+	// Cast a string to a 'C string'
+	fname := C.CString(filename)
+	defer C.free(unsafe.Pointer(fname))
+
+	var fd C.int
+	var size C.int64_t
+	if errno := C.open_file_size(fname, &fd, &size); errno != 0 {
+		return nil, newFileError("open", filename, syscall.Errno(errno))
+	}
+	defer C.close_fd(fd)
+
+	// One byte more than the size, so that a file of the expected size is read with a single buffer
+	capacity := int64(size) + 1
+	if capacity < minReadBufferSize {
+		capacity = minReadBufferSize
+	}
+	data := make([]byte, 0, capacity)
+	for {
+		free := data[len(data):cap(data)]
+		var nread C.int64_t
+		// The buffer does not contain Go pointers, so it can be passed to C
+		if errno := C.read_fd_content(fd, (*C.char)(unsafe.Pointer(&free[0])), C.int64_t(len(free)), &nread); errno != 0 {
+			return nil, newFileError("read", filename, syscall.Errno(errno))
+		}
+		data = data[:len(data)+int(nread)]
+		// read_fd_content stop before the end of the buffer only at EOF
+		if int(nread) < len(free) {
+			return data, nil
+		}
+		// The buffer is full, the file is bigger than expected: grow the buffer and continue
+		grown := make([]byte, len(data), 2*cap(data))
+		copy(grown, data)
+		data = grown
+	}
+}
+
+// ReadFileContentC wrapper method for retrieve content by a file
+func ReadFileContentC(filename string) string {
+	data, err := ReadFileContentCE(filename)
+	if err != nil {
+		log.Error("ReadFileContentC | Unable to read file [", filename, "] | ERR: ", err)
+		return ""
+	}
+	return string(data)
+}
+
+/* ==== C wrappers ==== */
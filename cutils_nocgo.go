@@ -0,0 +1,41 @@
+//go:build !cgo
+// +build !cgo
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Pure Go implementation of the C wrappers, used when cgo is disabled
+
+// GetFileSizeC return the byte lenght of a file, 0 in case of error
+func GetFileSizeC(filename string) int64 {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// ReadFileContentCE read the content of the given file
+func ReadFileContentCE(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	return data, nil
+}
+
+// ReadFileContentC return the content of the given file, an empty string in case of error
+func ReadFileContentC(filename string) string {
+	data, err := ReadFileContentCE(filename)
+	if err != nil {
+		log.Error("ReadFileContentC | Unable to read file [", filename, "] | ERR: ", err)
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+// writeTempFile write the data in a new file of the given directory, return the path
+func writeTempFile(t *testing.T, dir string, data []byte) string {
+	f, err := ioutil.TempFile(dir, "cutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+// countOpenFDs return the number of file descriptors open by the process, -1 if not available
+func countOpenFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+func TestReadFileContentCE(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Sizes around the initial buffer (512 bytes), in order to check the growth of the buffer
+	for _, size := range []int{0, 1, 511, 512, 513, 1 << 20} {
+		data := make([]byte, size)
+		rand.Read(data)
+		filename := writeTempFile(t, dir, data)
+		content, err := ReadFileContentCE(filename)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("size %d: read %d bytes, content mismatch", size, len(content))
+		}
+		if GetFileSizeC(filename) != int64(size) {
+			t.Errorf("size %d: GetFileSizeC = %d", size, GetFileSizeC(filename))
+		}
+		if ReadFileContentC(filename) != string(data) {
+			t.Errorf("size %d: ReadFileContentC content mismatch", size)
+		}
+	}
+}
+
+func TestReadFileContentCEProc(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc available only on Linux")
+	}
+	// The files of /proc report a size of 0, the content must be read until EOF as ioutil.ReadFile
+	for _, filename := range []string{"/proc/self/status", "/proc/self/maps", "/proc/meminfo"} {
+		if info, err := os.Stat(filename); err != nil || info.Size() != 0 {
+			t.Fatalf("%s: expected a file with size 0: %v", filename, err)
+		}
+		content, err := ReadFileContentCE(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) == 0 {
+			t.Errorf("%s: empty content", filename)
+		}
+	}
+	content, err := ReadFileContentCE("/proc/meminfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(content, []byte("MemTotal:")) {
+		t.Errorf("unexpected content of /proc/meminfo: %q", content)
+	}
+}
+
+func TestReadFileContentCEErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = ReadFileContentCE(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if ReadFileContentC(filepath.Join(dir, "missing")) != "" {
+		t.Error("expected an empty content for a missing file")
+	}
+	if GetFileSizeC(filepath.Join(dir, "missing")) != 0 {
+		t.Error("expected 0 for the size of a missing file")
+	}
+	// A directory can be opened but not read
+	if _, err = ReadFileContentCE(dir); err == nil {
+		t.Error("expected an error reading a directory")
+	}
+}
+
+func TestReadFileContentCEQuick(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fds := countOpenFDs()
+	check := func(data []byte) bool {
+		filename := writeTempFile(t, dir, data)
+		defer os.Remove(filename)
+		content, err := ReadFileContentCE(filename)
+		return err == nil && bytes.Equal(content, data)
+	}
+	if err = quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 100; i++ {
+		ReadFileContentCE(filepath.Join(dir, "missing"))
+		ReadFileContentCE(dir)
+	}
+	// Every descriptor must be closed, also on error
+	if fds != -1 && countOpenFDs() != fds {
+		t.Errorf("file descriptors leaked: %d open before, %d after", fds, countOpenFDs())
+	}
+}
+
+// TestReadFileContentCEConcurrent is meant to be run with -race (and with -asan/-msan for the cgo implementation)
+func TestReadFileContentCEConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := make([]string, 8)
+	expected := make([][]byte, len(files))
+	for i := range files {
+		expected[i] = make([]byte, rand.Intn(1<<16))
+		rand.Read(expected[i])
+		files[i] = writeTempFile(t, dir, expected[i])
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				n := (g + i) % len(files)
+				content, err := ReadFileContentCE(files[n])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(content, expected[n]) {
+					t.Errorf("content mismatch for %s", files[n])
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,248 @@
+package utils
+
+import "unicode/utf8"
+
+// Metric is a distance between two strings: 0 for equal strings, greater for different ones.
+// All the metrics compare the strings rune by rune, so they are Unicode aware.
+type Metric interface {
+	Distance(a, b string) float64
+}
+
+// SimilarityMetric is a Metric able to return a normalized similarity score: 1 for equal strings, 0 for completely different ones
+type SimilarityMetric interface {
+	Metric
+	Similarity(a, b string) float64
+}
+
+// LevenshteinMetric count the insertions, deletions and substitutions
+type LevenshteinMetric struct{}
+
+// Distance return the Levenshtein distance
+func (LevenshteinMetric) Distance(a, b string) float64 {
+	return float64(Levenshtein(a, b))
+}
+
+// Similarity return the Levenshtein distance normalized by the length of the longest string
+func (LevenshteinMetric) Similarity(a, b string) float64 {
+	return normalizedSimilarity(Levenshtein(a, b), a, b)
+}
+
+// DamerauLevenshteinMetric count the insertions, deletions, substitutions and transpositions of adjacent runes
+type DamerauLevenshteinMetric struct{}
+
+// Distance return the Damerau-Levenshtein distance
+func (DamerauLevenshteinMetric) Distance(a, b string) float64 {
+	return float64(DamerauLevenshtein(a, b))
+}
+
+// Similarity return the Damerau-Levenshtein distance normalized by the length of the longest string
+func (DamerauLevenshteinMetric) Similarity(a, b string) float64 {
+	return normalizedSimilarity(DamerauLevenshtein(a, b), a, b)
+}
+
+// HammingMetric count the positions with different runes
+type HammingMetric struct{}
+
+// Distance return the Hamming distance
+func (HammingMetric) Distance(a, b string) float64 {
+	return float64(Hamming(a, b))
+}
+
+// Similarity return the Hamming distance normalized by the length of the longest string
+func (HammingMetric) Similarity(a, b string) float64 {
+	return normalizedSimilarity(Hamming(a, b), a, b)
+}
+
+// LCSMetric count the runes that are not part of the longest common subsequence
+type LCSMetric struct{}
+
+// Distance return the number of runes to delete from both the strings in order to obtain the longest common subsequence
+func (LCSMetric) Distance(a, b string) float64 {
+	la, lb := utf8.RuneCountInString(a), utf8.RuneCountInString(b)
+	return float64(la + lb - 2*LongestCommonSubsequence(a, b))
+}
+
+// Similarity return the ratio of runes that are part of the longest common subsequence
+func (LCSMetric) Similarity(a, b string) float64 {
+	la, lb := utf8.RuneCountInString(a), utf8.RuneCountInString(b)
+	if la+lb == 0 {
+		return 1
+	}
+	return float64(2*LongestCommonSubsequence(a, b)) / float64(la+lb)
+}
+
+// JaroWinklerMetric is the Jaro similarity that favour the strings with a common prefix, tipically used for short strings like names
+type JaroWinklerMetric struct{}
+
+// Distance return 1 - the Jaro-Winkler similarity
+func (JaroWinklerMetric) Distance(a, b string) float64 {
+	return 1 - JaroWinkler(a, b)
+}
+
+// Similarity return the Jaro-Winkler similarity
+func (JaroWinklerMetric) Similarity(a, b string) float64 {
+	return JaroWinkler(a, b)
+}
+
+// DamerauLevenshtein return the edit distance between the two strings, counting the transposition of two adjacent runes as a single edit.
+// The unrestricted version of the algorithm is used, so a substring can be edited more than once.
+func DamerauLevenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	la, lb := len(ra), len(rb)
+	if la == 0 {
+		return lb
+	}
+	if lb == 0 {
+		return la
+	}
+	maxDistance := la + lb
+	// Last row in which the rune was found
+	lastRow := make(map[rune]int)
+	d := make([][]int, la+2)
+	for i := range d {
+		d[i] = make([]int, lb+2)
+	}
+	d[0][0] = maxDistance
+	for i := 0; i <= la; i++ {
+		d[i+1][0] = maxDistance
+		d[i+1][1] = i
+	}
+	for j := 0; j <= lb; j++ {
+		d[0][j+1] = maxDistance
+		d[1][j+1] = j
+	}
+	for i := 1; i <= la; i++ {
+		lastCol := 0
+		for j := 1; j <= lb; j++ {
+			i1, j1 := lastRow[rb[j-1]], lastCol
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+				lastCol = j
+			}
+			d[i+1][j+1] = min3(d[i][j]+cost, d[i+1][j]+1, d[i][j+1]+1)
+			if transposition := d[i1][j1] + (i - i1 - 1) + 1 + (j - j1 - 1); transposition < d[i+1][j+1] {
+				d[i+1][j+1] = transposition
+			}
+		}
+		lastRow[ra[i-1]] = i
+	}
+	return d[la+1][lb+1]
+}
+
+// Hamming return the number of positions in which the runes of the two strings are different.
+// If the strings have a different length, the exceeding runes are counted as different.
+func Hamming(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	distance := len(rb) - len(ra)
+	for i := range ra {
+		if ra[i] != rb[i] {
+			distance++
+		}
+	}
+	return distance
+}
+
+// LongestCommonSubsequence return the length (in runes) of the longest subsequence common to the two strings
+func LongestCommonSubsequence(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			if ra[i-1] == rb[j-1] {
+				current[j] = prev[j-1] + 1
+			} else if prev[j] > current[j-1] {
+				current[j] = prev[j]
+			} else {
+				current[j] = current[j-1]
+			}
+		}
+		prev, current = current, prev
+	}
+	return prev[len(rb)]
+}
+
+// Jaro return the Jaro similarity between the two strings, in the range [0, 1]
+func Jaro(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	// The runes are considered matching only if not farther than window
+	window := len(ra)
+	if len(rb) > window {
+		window = len(rb)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA, matchedB := make([]bool, len(ra)), make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		start, end := i-window, i+window+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(rb) {
+			end = len(rb)
+		}
+		for j := start; j < end; j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	// Count the matching runes that are not in the same order
+	transpositions, k := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[k] {
+			k++
+		}
+		if ra[i] != rb[k] {
+			transpositions++
+		}
+		k++
+	}
+	m := float64(matches)
+	return (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+}
+
+// JaroWinkler return the Jaro similarity increased for the strings with a common prefix (up to 4 runes), in the range [0, 1]
+func JaroWinkler(a, b string) float64 {
+	const prefixScale, maxPrefix = 0.1, 4
+	similarity := Jaro(a, b)
+	ra, rb := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && prefix < maxPrefix && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return similarity + float64(prefix)*prefixScale*(1-similarity)
+}
+
+// normalizedSimilarity convert an edit distance in a similarity score using the length of the longest string
+func normalizedSimilarity(distance int, a, b string) float64 {
+	length := utf8.RuneCountInString(a)
+	if lb := utf8.RuneCountInString(b); lb > length {
+		length = lb
+	}
+	if length == 0 {
+		return 1
+	}
+	return 1 - float64(distance)/float64(length)
+}
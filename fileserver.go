@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// errRangeNotSatisfiable is returned by parseRange when the range is outside the file
+	errRangeNotSatisfiable = errors.New("range not satisfiable")
+	// errMultipleRanges is returned by parseRange for the requests of more than one range, that are served with the whole file
+	errMultipleRanges = errors.New("multiple ranges not supported")
+	// errRangeUnit is returned by parseRange for a unit different from bytes, the header is ignored (RFC 7233)
+	errRangeUnit = errors.New("range unit not supported")
+)
+
+// FileServerOptions contains the parameters of the FileServer
+type FileServerOptions struct {
+	// StripPrefix is removed from the path of the request before resolve it in the root (ex: "/static").
+	// The prefix must be followed by a "/" or the end of the path: "/static" match "/static/a" but not "/staticfoo".
+	StripPrefix string
+	// IndexNames are the files served for the request of a directory (ex: "index.html")
+	IndexNames []string
+	// DirectoryListing enable the HTML list of the files for the directories without an index
+	DirectoryListing bool
+	// AllowHidden allow to serve the files and the directories that start with a dot (ex: .git)
+	AllowHidden bool
+	// Attachment send the files with "Content-Disposition: attachment" (download) instead of inline
+	Attachment bool
+	// MaxAge is the max-age of the Cache-Control header, 0 for no Cache-Control header
+	MaxAge time.Duration
+	// SSL add the security headers reserved to the HTTPS connections (see SecureRequest)
+	SSL bool
+	// Policy contains the security headers of the responses, nil for the headers of SecureRequest
+	Policy *SecurityPolicy
+}
+
+// FileServer is a fasthttp handler that serve the files of a root directory.
+// The files outside the root (also through a symlink) and the hidden files are never served.
+// The responses contains MIME type, Content-Disposition, ETag, Last-Modified and the security headers of the Policy,
+// and support the conditional (If-None-Match, If-Modified-Since) and the range (Range, If-Range) requests.
+type FileServer struct {
+	validator *PathValidator
+	opts      FileServerOptions
+}
+
+// NewFileServer return a FileServer rooted at the given directory. opts can be nil.
+func NewFileServer(root string, opts *FileServerOptions) (*FileServer, error) {
+	if opts == nil {
+		opts = &FileServerOptions{}
+	}
+	validator, err := NewPathValidator(root)
+	if err != nil {
+		return nil, err
+	}
+	validator.AllowHidden = opts.AllowHidden
+	return &FileServer{validator: validator, opts: *opts}, nil
+}
+
+// Handler return the fasthttp handler of the FileServer
+func (s *FileServer) Handler() fasthttp.RequestHandler {
+	return s.Handle
+}
+
+// Handle serve the file of the request
+func (s *FileServer) Handle(ctx *fasthttp.RequestCtx) {
+	if s.opts.Policy != nil {
+		s.opts.Policy.Apply(ctx, s.opts.SSL)
+	} else {
+		SecureRequest(ctx, s.opts.SSL)
+	}
+	if !ctx.IsGet() && !ctx.IsHead() {
+		ctx.Response.Header.Set(fasthttp.HeaderAllow, "GET, HEAD")
+		serveStatus(ctx, fasthttp.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	requestPath := string(ctx.Path())
+	if s.opts.StripPrefix != "" {
+		if !hasPathPrefix(requestPath, s.opts.StripPrefix) {
+			serveStatus(ctx, fasthttp.StatusNotFound, "Not found")
+			return
+		}
+		requestPath = requestPath[len(s.opts.StripPrefix):]
+	}
+	filename, err := s.resolve(requestPath)
+	if err != nil {
+		log.Debug("FileServer | Path [", requestPath, "] rejected | ERR: ", err)
+		s.serveError(ctx, err)
+		return
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		s.serveError(ctx, err)
+		return
+	}
+	if info.IsDir() {
+		s.serveDir(ctx, requestPath, filename)
+		return
+	}
+	s.serveFile(ctx, filename, info)
+}
+
+// hasPathPrefix return true if the path start with the prefix followed by a "/" or the end of the path
+func hasPathPrefix(requestPath, prefix string) bool {
+	if !strings.HasPrefix(requestPath, prefix) {
+		return false
+	}
+	rest := requestPath[len(prefix):]
+	return rest == "" || rest[0] == '/' || strings.HasSuffix(prefix, "/")
+}
+
+// resolve return the path of the file of the request, verifying that is inside the root also after the resolution of the symlinks
+func (s *FileServer) resolve(requestPath string) (string, error) {
+	if requestPath = strings.TrimLeft(requestPath, "/"); requestPath == "" {
+		return s.validator.Root(), nil
+	}
+	return s.validator.Resolve(requestPath)
+}
+
+// serveStatus send the status code with a plain text message. Unlike ctx.Error and ctx.NotFound, the response
+// is not reset, so the headers already set (security headers, Allow, Content-Range ...) are kept.
+func serveStatus(ctx *fasthttp.RequestCtx, statusCode int, msg string) {
+	ctx.SetStatusCode(statusCode)
+	ctx.SetContentType("text/plain; charset=utf-8")
+	ctx.SetBodyString(msg)
+}
+
+// notModified send the 304 status keeping the headers already set (ETag, Last-Modified, Cache-Control and the
+// security headers), ctx.NotModified reset them
+func notModified(ctx *fasthttp.RequestCtx) {
+	ctx.ResetBody()
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+}
+
+// serveError send the status code of the given error
+func (s *FileServer) serveError(ctx *fasthttp.RequestCtx, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		serveStatus(ctx, fasthttp.StatusNotFound, "Not found")
+	case errors.Is(err, os.ErrPermission) || PathErrorReasonOf(err) != 0:
+		serveStatus(ctx, fasthttp.StatusForbidden, "Forbidden")
+	default:
+		log.Error("FileServer | Unable to serve the file | ERR: ", err)
+		serveStatus(ctx, fasthttp.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// serveDir serve the index of the directory or the list of the files
+func (s *FileServer) serveDir(ctx *fasthttp.RequestCtx, requestPath, dirname string) {
+	if !strings.HasSuffix(requestPath, "/") {
+		// The relative links of the index work only with the trailing slash
+		ctx.Redirect(string(ctx.Path())+"/", fasthttp.StatusMovedPermanently)
+		return
+	}
+	for _, index := range s.opts.IndexNames {
+		// The index is resolved as the other files, so a symlink outside the root is never served
+		filename, err := s.resolve(requestPath + index)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Debug("FileServer | Index [", requestPath+index, "] rejected | ERR: ", err)
+			}
+			continue
+		}
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			s.serveFile(ctx, filename, info)
+			return
+		}
+	}
+	if !s.opts.DirectoryListing {
+		serveStatus(ctx, fasthttp.StatusForbidden, "Forbidden")
+		return
+	}
+	dir, err := os.Open(dirname)
+	if err != nil {
+		s.serveError(ctx, err)
+		return
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		s.serveError(ctx, err)
+		return
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].IsDir() != infos[j].IsDir() {
+			return infos[i].IsDir()
+		}
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	var buf bytes.Buffer
+	title := html.EscapeString(string(ctx.Path()))
+	buf.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + title + "</title></head><body>\n<h1>" + title + "</h1>\n<ul>\n")
+	if requestPath != "/" {
+		buf.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !s.opts.AllowHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if info.IsDir() {
+			name += "/"
+		}
+		// The "./" prefix avoid that a name with a colon is interpreted as a scheme
+		buf.WriteString("<li><a href=\"./" + html.EscapeString((&url.URL{Path: name}).EscapedPath()) + "\">" + html.EscapeString(name) + "</a></li>\n")
+	}
+	buf.WriteString("</ul>\n</body></html>\n")
+	ctx.SetContentType("text/html; charset=utf-8")
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	ctx.SetBody(buf.Bytes())
+}
+
+// fileETag return the ETag of the file, computed from the size and the modification time
+func fileETag(info os.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`
+}
+
+// matchETag return true if the header (If-None-Match) contains the ETag, using the weak comparison
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveFile send the file, or the requested range, with the cache headers
+func (s *FileServer) serveFile(ctx *fasthttp.RequestCtx, filename string, info os.FileInfo) {
+	file, err := os.Open(filename)
+	if err != nil {
+		s.serveError(ctx, err)
+		return
+	}
+	size := info.Size()
+	etag := fileETag(info)
+	lastModified := info.ModTime().UTC().Truncate(time.Second)
+
+	header := &ctx.Response.Header
+	header.Set(fasthttp.HeaderETag, etag)
+	header.SetLastModified(lastModified)
+	header.Set(fasthttp.HeaderAcceptRanges, "bytes")
+	if s.opts.MaxAge > 0 {
+		header.Set(fasthttp.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(s.opts.MaxAge/time.Second)))
+	}
+
+	// If-None-Match has precedence over If-Modified-Since
+	if ifNoneMatch := string(ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch)); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag) {
+			file.Close()
+			notModified(ctx)
+			return
+		}
+	} else if !ctx.IfModifiedSince(lastModified) {
+		file.Close()
+		notModified(ctx)
+		return
+	}
+
+	sniff := make([]byte, mimeSniffLen)
+	n, _ := file.ReadAt(sniff, 0)
+	ctx.SetContentType(DetectMimeType(filename, sniff[:n]))
+	if encoding := ContentEncodingByExtension(filename); encoding != "" {
+		header.Set(fasthttp.HeaderContentEncoding, encoding)
+	}
+	disposition := DispositionInline
+	if s.opts.Attachment {
+		disposition = DispositionAttachment
+	}
+	header.Set(fasthttp.HeaderContentDisposition, ContentDisposition(disposition, filename))
+
+	start, length := int64(0), size
+	if rangeHeader := string(ctx.Request.Header.Peek(fasthttp.HeaderRange)); rangeHeader != "" && s.rangeAllowed(ctx, etag, lastModified) {
+		switch start, length, err = parseRange(rangeHeader, size); err {
+		case nil:
+			ctx.SetStatusCode(fasthttp.StatusPartialContent)
+			header.SetContentRange(int(start), int(start+length-1), int(size))
+		case errMultipleRanges, errRangeUnit:
+			start, length = 0, size
+		default:
+			file.Close()
+			header.Del(fasthttp.HeaderContentDisposition)
+			header.Set(fasthttp.HeaderContentRange, "bytes */"+strconv.FormatInt(size, 10))
+			serveStatus(ctx, fasthttp.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+			return
+		}
+	}
+
+	if ctx.IsHead() {
+		file.Close()
+		header.SetContentLength(int(length))
+		return
+	}
+	// The file is closed by fasthttp after the body is sent
+	ctx.SetBodyStream(struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, start, length), file}, int(length))
+}
+
+// rangeAllowed return true if the Range header must be applied: without If-Range, or if the If-Range
+// contains the current ETag (strong comparison) or the current modification time
+func (s *FileServer) rangeAllowed(ctx *fasthttp.RequestCtx, etag string, lastModified time.Time) bool {
+	ifRange := ctx.Request.Header.Peek(fasthttp.HeaderIfRange)
+	if len(ifRange) == 0 {
+		return true
+	}
+	if ifRange[0] == '"' {
+		return string(ifRange) == etag
+	}
+	date, err := fasthttp.ParseHTTPDate(ifRange)
+	return err == nil && date.Equal(lastModified)
+}
+
+// parseRange parse the Range header of a file of the given size, returning the start and the length of the range.
+// Only a single range is supported, errMultipleRanges is returned for the others; errRangeUnit is returned
+// for the units different from bytes. In both the cases the header is ignored and the whole file is served.
+func parseRange(header string, size int64) (int64, int64, error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, errRangeUnit
+	}
+	spec := strings.TrimSpace(header[len("bytes="):])
+	if strings.IndexByte(spec, ',') != -1 {
+		return 0, 0, errMultipleRanges
+	}
+	i := strings.IndexByte(spec, '-')
+	if i == -1 {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	if first == "" {
+		// Suffix range: the last N byte
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, nil
+}
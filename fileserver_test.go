@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newTestFileServer create a root with a single file (hello.txt) and return the FileServer, the root must be removed by the caller
+func newTestFileServer(t *testing.T, opts *FileServerOptions) (*FileServer, string) {
+	root, err := ioutil.TempDir("", "fileserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewFileServer(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, root
+}
+
+// serveTest call the FileServer with a request of the given method and path, setupRequest can add the headers
+func serveTest(server *FileServer, method, path string, setupRequest func(*fasthttp.Request)) *fasthttp.RequestCtx {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(path)
+	if setupRequest != nil {
+		setupRequest(&ctx.Request)
+	}
+	server.Handle(&ctx)
+	return &ctx
+}
+
+// checkHeaders verify the status code and that the headers are present in the response
+func checkHeaders(t *testing.T, ctx *fasthttp.RequestCtx, statusCode int, headers ...string) {
+	t.Helper()
+	if ctx.Response.StatusCode() != statusCode {
+		t.Errorf("status = %d, expected %d", ctx.Response.StatusCode(), statusCode)
+	}
+	for _, header := range headers {
+		if len(ctx.Response.Header.Peek(header)) == 0 {
+			t.Errorf("status %d: missing header %s", statusCode, header)
+		}
+	}
+}
+
+// securityHeaders are the headers set by SecureRequest on every response
+var securityHeaders = []string{"X-Frame-Options", "X-Content-Type-Options"}
+
+func TestFileServerNotModifiedHeaders(t *testing.T) {
+	server, root := newTestFileServer(t, &FileServerOptions{MaxAge: time.Hour})
+	defer os.RemoveAll(root)
+
+	ctx := serveTest(server, "GET", "/hello.txt", nil)
+	checkHeaders(t, ctx, fasthttp.StatusOK, fasthttp.HeaderETag, fasthttp.HeaderLastModified)
+	etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag))
+	lastModified := string(ctx.Response.Header.Peek(fasthttp.HeaderLastModified))
+
+	expected := append([]string{fasthttp.HeaderETag, fasthttp.HeaderLastModified, fasthttp.HeaderCacheControl}, securityHeaders...)
+	ctx = serveTest(server, "GET", "/hello.txt", func(req *fasthttp.Request) {
+		req.Header.Set(fasthttp.HeaderIfNoneMatch, etag)
+	})
+	checkHeaders(t, ctx, fasthttp.StatusNotModified, expected...)
+	if len(ctx.Response.Body()) != 0 {
+		t.Errorf("unexpected body for 304: %q", ctx.Response.Body())
+	}
+
+	ctx = serveTest(server, "GET", "/hello.txt", func(req *fasthttp.Request) {
+		req.Header.Set(fasthttp.HeaderIfModifiedSince, lastModified)
+	})
+	checkHeaders(t, ctx, fasthttp.StatusNotModified, expected...)
+}
+
+func TestFileServerMethodNotAllowedHeaders(t *testing.T) {
+	server, root := newTestFileServer(t, nil)
+	defer os.RemoveAll(root)
+
+	ctx := serveTest(server, "POST", "/hello.txt", nil)
+	checkHeaders(t, ctx, fasthttp.StatusMethodNotAllowed, append([]string{fasthttp.HeaderAllow}, securityHeaders...)...)
+	if allow := string(ctx.Response.Header.Peek(fasthttp.HeaderAllow)); allow != "GET, HEAD" {
+		t.Errorf("Allow = %q", allow)
+	}
+}
+
+func TestFileServerRangeHeaders(t *testing.T) {
+	server, root := newTestFileServer(t, nil)
+	defer os.RemoveAll(root)
+
+	ctx := serveTest(server, "GET", "/hello.txt", func(req *fasthttp.Request) {
+		req.Header.Set(fasthttp.HeaderRange, "bytes=0-4")
+	})
+	checkHeaders(t, ctx, fasthttp.StatusPartialContent, fasthttp.HeaderContentRange)
+	if body := string(ctx.Response.Body()); body != "hello" {
+		t.Errorf("partial body = %q", body)
+	}
+
+	ctx = serveTest(server, "GET", "/hello.txt", func(req *fasthttp.Request) {
+		req.Header.Set(fasthttp.HeaderRange, "bytes=20-")
+	})
+	checkHeaders(t, ctx, fasthttp.StatusRequestedRangeNotSatisfiable, append([]string{fasthttp.HeaderContentRange}, securityHeaders...)...)
+	if contentRange := string(ctx.Response.Header.Peek(fasthttp.HeaderContentRange)); contentRange != "bytes */12" {
+		t.Errorf("Content-Range = %q, expected bytes */12", contentRange)
+	}
+	if len(ctx.Response.Header.Peek(fasthttp.HeaderContentDisposition)) != 0 {
+		t.Error("unexpected Content-Disposition for 416")
+	}
+
+	// A unit different from bytes is ignored
+	ctx = serveTest(server, "GET", "/hello.txt", func(req *fasthttp.Request) {
+		req.Header.Set(fasthttp.HeaderRange, "items=0-3")
+	})
+	checkHeaders(t, ctx, fasthttp.StatusOK)
+	if body := string(ctx.Response.Body()); body != "hello world\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestFileServerErrorHeaders(t *testing.T) {
+	server, root := newTestFileServer(t, &FileServerOptions{StripPrefix: "/static"})
+	defer os.RemoveAll(root)
+
+	checkHeaders(t, serveTest(server, "GET", "/static/missing.txt", nil), fasthttp.StatusNotFound, securityHeaders...)
+	checkHeaders(t, serveTest(server, "GET", "/staticfoo/hello.txt", nil), fasthttp.StatusNotFound, securityHeaders...)
+	checkHeaders(t, serveTest(server, "GET", "/static/", nil), fasthttp.StatusForbidden, securityHeaders...)
+	checkHeaders(t, serveTest(server, "GET", "/static/hello.txt", nil), fasthttp.StatusOK, securityHeaders...)
+}
+
+func TestFileServerEncodedFile(t *testing.T) {
+	server, root := newTestFileServer(t, nil)
+	defer os.RemoveAll(root)
+	var buf bytes.Buffer
+	if _, err := CompressStream(&buf, strings.NewReader("<svg></svg>\n"), CompressionGzip, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "logo.svgz"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := serveTest(server, "GET", "/logo.svgz", nil)
+	checkHeaders(t, ctx, fasthttp.StatusOK, fasthttp.HeaderContentEncoding)
+	if encoding := string(ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding)); encoding != CompressionGzip {
+		t.Errorf("Content-Encoding = %q, expected gzip", encoding)
+	}
+	if contentType := string(ctx.Response.Header.ContentType()); contentType != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, expected image/svg+xml", contentType)
+	}
+	if !bytes.Equal(ctx.Response.Body(), buf.Bytes()) {
+		t.Error("svgz body modified")
+	}
+}
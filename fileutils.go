@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The functions of this file return the error instead of logging it and returning a sentinel value.
+// The legacy helpers (ReadAllFile, IsFile, IsDir ...) are thin wrappers that log the error.
+
+// FileError records the helper and the file path that caused the error.
+// The os errors are wrapped, so they can be checked using errors.Is (ex: errors.Is(err, os.ErrNotExist)).
+type FileError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap return the underlying error
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// newFileError create a FileError, unpacking the os.PathError in order to avoid to repeat the path
+func newFileError(op, path string, err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return &FileError{Op: op, Path: path, Err: err}
+}
+
+// StatE return the FileInfo of the given path
+func StatE(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, newFileError("stat", path, err)
+	}
+	return info, nil
+}
+
+// IsFileE verify if the given path is not a directory
+func IsFileE(path string) (bool, error) {
+	info, err := StatE(path)
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// IsDirE verify if the given path is a directory
+func IsDirE(path string) (bool, error) {
+	info, err := StatE(path)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// ReadAllFileE return all the content of the given file
+func ReadAllFileE(filePath string) (string, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", newFileError("read", filePath, err)
+	}
+	return string(data), nil
+}
+
+// ReadAllFileInArrayE return the content of the given file tokenized by the new line
+func ReadAllFileInArrayE(filePath string) ([]string, error) {
+	data, err := ReadAllFileE(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(data, "\n"), nil
+}
+
+// GetFileModificationE return the last modification time of the given file in a UNIX time format
+func GetFileModificationE(filePath string) (int64, error) {
+	info, err := StatE(filePath)
+	if err != nil {
+		return -1, err
+	}
+	return info.ModTime().Unix(), nil
+}
+
+// GetFileDateE return the date (Europe/Rome timezone) in which the file was (latest) modified
+func GetFileDateE(filePath string) (string, error) {
+	unixTimestamp, err := GetFileModificationE(filePath)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		return "", newFileError("date", filePath, err)
+	}
+	return time.Unix(unixTimestamp, 0).In(loc).Format("2006-01-02 15:04:05"), nil
+}
+
+// ReadFilePathE return every (sub)file path of the given directory.
+// Only an error on the root is returned: the entries that can not be read (ex: a broken symlink or a directory
+// without permission) are logged and skipped. The symlinks are followed as os.Stat does.
+func ReadFilePathE(path string) ([]string, error) {
+	fileList := []string{}
+	err := filepath.Walk(path, func(file string, f os.FileInfo, err error) error {
+		if err != nil {
+			if file == path {
+				return newFileError("walk", file, err)
+			}
+			log.Warn("ReadFilePathE | Skipping [", file, "] | ERR: ", err)
+			return nil
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			// Walk does not follow the symlinks, verify the target
+			if f, err = os.Stat(file); err != nil {
+				log.Warn("ReadFilePathE | Skipping [", file, "] | ERR: ", err)
+				return nil
+			}
+		}
+		if !f.IsDir() {
+			fileList = append(fileList, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileList, nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestReadFilePathE(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks not available")
+	}
+	root, err := ioutil.TempDir("", "readfilepath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.MkdirAll(filepath.Join(root, "sub", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/dir/c.txt"} {
+		if err = ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(name)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The broken symlink is skipped, the symlink to a file is listed and the one to a directory is not
+	for link, target := range map[string]string{"broken": "missing", "link.txt": "a.txt", "linkdir": "sub"} {
+		if err = os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		filepath.Join(root, "a.txt"),
+		filepath.Join(root, "link.txt"),
+		filepath.Join(root, "sub", "b.txt"),
+		filepath.Join(root, "sub", "dir", "c.txt"),
+	}
+	files, err := ReadFilePathE(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("ReadFilePathE = %v, expected %v", files, expected)
+	}
+	if files = ReadFilePath(root); len(files) != len(expected) {
+		t.Errorf("ReadFilePath = %v, expected %v", files, expected)
+	}
+
+	// Only the error on the root is returned
+	if _, err = ReadFilePathE(filepath.Join(root, "missing")); err == nil {
+		t.Error("expected an error for a missing root")
+	}
+}
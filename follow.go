@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultFollowInterval is the interval used for poll the file when FollowOptions.PollInterval is not set
+const defaultFollowInterval = 250 * time.Millisecond
+
+// FollowOptions contains the parameters for a Follower
+type FollowOptions struct {
+	// Offset is the position from which start to read the file, tipically saved from Follower.Offset before a restart.
+	// If greater than the size of the file, the file is considered rotated and is read from the beginning.
+	Offset int64
+	// FromEnd skip the content already present in the file when Offset is 0
+	FromEnd bool
+	// PollInterval is the interval between two checks of the file
+	PollInterval time.Duration
+	// Filter select the lines to emit, nil emit every line
+	Filter *LineFilter
+}
+
+// FollowLine is a line read by the Follower
+type FollowLine struct {
+	// Text is the content of the line, without the new line
+	Text string
+	// Offset is the position in the file just after the line
+	Offset int64
+}
+
+// Follower is delegated to stream the lines appended to a file (like 'tail -F').
+// It detect the logrotate rename/recreate (the old file is drained before switch to the new one) and the copytruncate.
+type Follower struct {
+	filename string
+	opts     FollowOptions
+	file     *os.File
+	info     os.FileInfo
+	partial  []byte
+	offset   int64
+}
+
+// NewFollower initialize a Follower for the given file. The file is opened when Run is called.
+func NewFollower(filename string, opts FollowOptions) *Follower {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultFollowInterval
+	}
+	return &Follower{filename: filename, opts: opts, offset: opts.Offset}
+}
+
+// Offset return the position after the last complete line processed, it can be saved for resume the reading after a restart
+func (f *Follower) Offset() int64 {
+	return atomic.LoadInt64(&f.offset)
+}
+
+// Run follow the file until the context is done, calling the handler for every new line that satisfy the filter.
+// It return the context error, or the error returned by the handler.
+func (f *Follower) Run(ctx context.Context, handler func(FollowLine) error) error {
+	defer f.close()
+	ticker := time.NewTicker(f.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.poll(handler); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lines run the Follower in a new goroutine and return the channel of the lines.
+// Both the channels are closed when the context is done; the error channel receive the error that stopped the Follower, if any.
+func (f *Follower) Lines(ctx context.Context) (<-chan FollowLine, <-chan error) {
+	lines := make(chan FollowLine)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(lines)
+		err := f.Run(ctx, func(line FollowLine) error {
+			select {
+			case lines <- line:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && err != ctx.Err() {
+			errs <- err
+		}
+	}()
+	return lines, errs
+}
+
+// poll read the new data available and verify if the file was rotated or truncated
+func (f *Follower) poll(handler func(FollowLine) error) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			if os.IsNotExist(err) { // Not yet created, retry at the next poll
+				log.Trace("Follower | File [", f.filename, "] does not exist yet")
+				return nil
+			}
+			return err
+		}
+	}
+	if err := f.read(handler); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(f.filename)
+	if err != nil {
+		if os.IsNotExist(err) { // Rotated, the new file is not yet created
+			return nil
+		}
+		return err
+	}
+	if !os.SameFile(f.info, info) {
+		log.Debug("Follower | File [", f.filename, "] rotated, reopening")
+		// Drain the data written in the old file after the last read
+		if err = f.read(handler); err != nil {
+			return err
+		}
+		// The old file will not be written anymore, the unterminated last line is complete
+		if err = f.flush(handler); err != nil {
+			return err
+		}
+		f.close()
+		atomic.StoreInt64(&f.offset, 0)
+		return f.poll(handler)
+	}
+	if info.Size() < f.Offset()+int64(len(f.partial)) {
+		log.Debug("Follower | File [", f.filename, "] truncated, restarting from the beginning")
+		// The content was copied elsewhere before the truncation, the unterminated last line is complete
+		if err = f.flush(handler); err != nil {
+			return err
+		}
+		if _, err = f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		atomic.StoreInt64(&f.offset, 0)
+		return f.read(handler)
+	}
+	return nil
+}
+
+// open open the file and move to the offset to resume
+func (f *Follower) open() error {
+	file, err := os.Open(f.filename)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	offset := f.Offset()
+	if offset == 0 && f.opts.FromEnd {
+		offset = info.Size()
+		// Only the first opening can skip the content, the rotated files have to be read entirely
+		f.opts.FromEnd = false
+	} else if offset > info.Size() {
+		offset = 0
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.info, f.partial = file, info, f.partial[:0]
+	atomic.StoreInt64(&f.offset, offset)
+	return nil
+}
+
+// read consume the data available in the file and call the handler for every complete line
+func (f *Follower) read(handler func(FollowLine) error) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.file.Read(buf)
+		if n > 0 {
+			f.partial = append(f.partial, buf[:n]...)
+			if herr := f.emit(handler); herr != nil {
+				return herr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// emit call the handler for every complete line in the buffer, keeping the incomplete one for the next read.
+// The offset is advanced only after the line is delivered, so a line rejected by the handler is read again after a restart.
+func (f *Follower) emit(handler func(FollowLine) error) error {
+	start := 0
+	defer func() {
+		f.partial = append(f.partial[:0], f.partial[start:]...)
+	}()
+	for {
+		i := bytes.IndexByte(f.partial[start:], '\n')
+		if i == -1 {
+			return nil
+		}
+		if err := f.deliver(handler, f.partial[start:start+i], int64(i+1)); err != nil {
+			return err
+		}
+		start += i + 1
+	}
+}
+
+// deliver call the handler for the line if it satisfy the filter, then advance the offset of size bytes
+func (f *Follower) deliver(handler func(FollowLine) error, line []byte, size int64) error {
+	offset := f.Offset() + size
+	if f.opts.Filter.Match(line) {
+		if err := handler(FollowLine{Text: string(line), Offset: offset}); err != nil {
+			return err
+		}
+	}
+	atomic.StoreInt64(&f.offset, offset)
+	return nil
+}
+
+// flush deliver the last line of the file when it is not terminated by a new line, before switch to another file
+func (f *Follower) flush(handler func(FollowLine) error) error {
+	if len(f.partial) == 0 {
+		return nil
+	}
+	if err := f.deliver(handler, f.partial, int64(len(f.partial))); err != nil {
+		return err
+	}
+	f.partial = f.partial[:0]
+	return nil
+}
+
+// close release the file currently followed
+func (f *Follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file, f.info = nil, nil
+	}
+	f.partial = f.partial[:0]
+}
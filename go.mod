@@ -3,7 +3,8 @@ module github.com/alessiosavi/GoUtils
 go 1.13
 
 require (
-	github.com/klauspost/compress v1.8.4 // indirect
+	github.com/andybalholm/brotli v1.0.6
+	github.com/klauspost/compress v1.8.4
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
 	github.com/pierrec/lz4 v2.3.0+incompatible
 	github.com/sirupsen/logrus v1.4.2
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultMinCompressSize is the size under which the responses are not compressed: the headers of the compression cost more than the saving
+const DefaultMinCompressSize = 256
+
+// DefaultHTTPEncodings are the encodings offered by the server, in order of preference
+var DefaultHTTPEncodings = []string{CompressionZstd, CompressionBrotli, CompressionGzip, CompressionIdentity}
+
+// compressedMimeTypes are the MIME types of data already compressed, that would not gain anything from a new compression
+var compressedMimeTypes = map[string]struct{}{
+	"application/zip":              {},
+	"application/gzip":             {},
+	"application/x-gzip":           {},
+	"application/x-bzip2":          {},
+	"application/x-xz":             {},
+	"application/x-7z-compressed":  {},
+	"application/x-rar-compressed": {},
+	"application/vnd.rar":          {},
+	"application/zstd":             {},
+	"application/x-lz4":            {},
+	"application/x-snappy-framed":  {},
+	"application/pdf":              {},
+	"application/java-archive":     {},
+	"font/woff":                    {},
+	"font/woff2":                   {},
+}
+
+// compressedMimePrefixes are the families of MIME types already compressed
+var compressedMimePrefixes = []string{"image/", "video/", "audio/", "application/vnd.openxmlformats-officedocument."}
+
+// uncompressedMimeTypes are the exceptions of compressedMimePrefixes
+var uncompressedMimeTypes = map[string]struct{}{
+	"image/svg+xml": {},
+	"image/bmp":     {},
+	"image/x-icon":  {},
+	"audio/wav":     {},
+	"audio/x-wav":   {},
+}
+
+// HTTPCompressOptions contains the parameters of the compression of the HTTP responses
+type HTTPCompressOptions struct {
+	// Encodings are the encodings offered, in order of preference. nil for DefaultHTTPEncodings
+	Encodings []string
+	// Level is the compression level, 0 for the default level of every algorithm
+	Level int
+	// MinSize is the size under which the responses are not compressed, 0 for DefaultMinCompressSize
+	MinSize int
+}
+
+func (o *HTTPCompressOptions) encodings() []string {
+	if o == nil || len(o.Encodings) == 0 {
+		return DefaultHTTPEncodings
+	}
+	return o.Encodings
+}
+
+func (o *HTTPCompressOptions) level() int {
+	if o == nil {
+		return 0
+	}
+	return o.Level
+}
+
+func (o *HTTPCompressOptions) minSize() int {
+	if o == nil || o.MinSize <= 0 {
+		return DefaultMinCompressSize
+	}
+	return o.MinSize
+}
+
+// parseAcceptEncoding return the q-value of every coding of the Accept-Encoding header
+func parseAcceptEncoding(header string) map[string]float64 {
+	codings := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		if coding != "*" {
+			if normalized := normalizeCompression(coding); normalized != "" {
+				coding = normalized
+			}
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				value, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil || value < 0 || value > 1 {
+					value = 0
+				}
+				q = value
+			}
+		}
+		// The same coding can be listed more than once, keep the best q-value
+		if old, ok := codings[coding]; !ok || q > old {
+			codings[coding] = q
+		}
+	}
+	return codings
+}
+
+// NegotiateEncoding return the encoding among the offered ones (in order of preference) with the highest q-value in the
+// given Accept-Encoding header. The order of the offered encodings break the ties.
+// An empty string is returned if no offered encoding is acceptable, identity is always acceptable unless explicitly refused.
+func NegotiateEncoding(acceptEncoding string, offered []string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return CompressionIdentity
+	}
+	codings := parseAcceptEncoding(acceptEncoding)
+	wildcard, hasWildcard := codings["*"]
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		encoding = normalizeCompression(encoding)
+		if encoding == "" {
+			continue
+		}
+		q, ok := codings[encoding]
+		if !ok {
+			switch {
+			case hasWildcard:
+				q = wildcard
+			case encoding == CompressionIdentity:
+				q = 1
+			}
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// IsCompressedMimeType return true if the given MIME type (the Content-Type header) is already compressed, like images, videos and archives
+func IsCompressedMimeType(mimeType string) bool {
+	if i := strings.IndexByte(mimeType, ';'); i != -1 {
+		mimeType = mimeType[:i]
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if _, ok := compressedMimeTypes[mimeType]; ok {
+		return true
+	}
+	if _, ok := uncompressedMimeTypes[mimeType]; ok {
+		return false
+	}
+	for _, prefix := range compressedMimePrefixes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addVary add the given header to the Vary header of the response, if not already present
+func addVary(header *fasthttp.ResponseHeader, value string) {
+	vary := string(header.Peek(fasthttp.HeaderVary))
+	for _, field := range strings.Split(vary, ",") {
+		if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+			return
+		}
+	}
+	if vary == "" {
+		header.Set(fasthttp.HeaderVary, value)
+	} else {
+		header.Set(fasthttp.HeaderVary, vary+", "+value)
+	}
+}
+
+// negotiateResponseEncoding negotiate the encoding of the response and add the Vary header.
+// An empty string is returned if no offered encoding is acceptable.
+func negotiateResponseEncoding(ctx *fasthttp.RequestCtx, opts *HTTPCompressOptions) string {
+	addVary(&ctx.Response.Header, fasthttp.HeaderAcceptEncoding)
+	return NegotiateEncoding(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)), opts.encodings())
+}
+
+// CompressHandler is a middleware that compress the response of the handler with the best encoding accepted by the client.
+// The responses already encoded, too small or with an already compressed MIME type are sent as they are.
+// When the handler set a generic Content-Type (application/octet-stream) the MIME type is recognized from the extension of the path.
+func CompressHandler(handler fasthttp.RequestHandler, opts *HTTPCompressOptions) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		handler(ctx)
+		compressResponse(ctx, opts)
+	}
+}
+
+// compressResponse compress the body of the response, if worth it
+func compressResponse(ctx *fasthttp.RequestCtx, opts *HTTPCompressOptions) {
+	status := ctx.Response.StatusCode()
+	if ctx.IsHead() || status < 200 || status == fasthttp.StatusNoContent || status == fasthttp.StatusNotModified {
+		return
+	}
+	// The byte ranges of a partial response refer to the identity body, they would not match the encoded one
+	if status == fasthttp.StatusPartialContent || len(ctx.Response.Header.Peek(fasthttp.HeaderContentRange)) > 0 {
+		return
+	}
+	// The files stored already compressed (ex: svgz) have the MIME type of the decoded content
+	if len(ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 || ContentEncodingByExtension(string(ctx.Path())) != "" {
+		return
+	}
+	contentType := string(ctx.Response.Header.ContentType())
+	if strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType, _ = RecognizeFormat(string(ctx.Path()))
+	}
+	if IsCompressedMimeType(contentType) {
+		return
+	}
+	// The Vary header is needed also when the response is not compressed, the caches must not reuse it for other clients
+	encoding := negotiateResponseEncoding(ctx, opts)
+	body := ctx.Response.Body()
+	if encoding == "" || encoding == CompressionIdentity || len(body) < opts.minSize() {
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := CompressStream(&buf, bytes.NewReader(body), encoding, &CompressOptions{Level: opts.level()}); err != nil {
+		return
+	}
+	ctx.Response.SetBody(buf.Bytes())
+	ctx.Response.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+	// The encoded body differ from the identity one, so a strong ETag become weak
+	if etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag)); etag != "" && !strings.HasPrefix(etag, "W/") {
+		ctx.Response.Header.Set(fasthttp.HeaderETag, "W/"+etag)
+	}
+}
+
+// WriteCompressed write the data in the response, compressed with the best encoding accepted by the client
+func WriteCompressed(ctx *fasthttp.RequestCtx, data []byte, contentType string, opts *HTTPCompressOptions) error {
+	ctx.SetContentType(contentType)
+	encoding := negotiateResponseEncoding(ctx, opts)
+	if encoding == "" {
+		ctx.SetStatusCode(fasthttp.StatusNotAcceptable)
+		return ErrUnknownCompression
+	}
+	if encoding == CompressionIdentity || len(data) < opts.minSize() || IsCompressedMimeType(contentType) {
+		ctx.SetBody(data)
+		return nil
+	}
+	ctx.ResetBody()
+	if _, err := CompressStream(ctx, bytes.NewReader(data), encoding, &CompressOptions{Level: opts.level()}); err != nil {
+		ctx.ResetBody()
+		return err
+	}
+	ctx.Response.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+	return nil
+}
+
+// ServeTailFile write the last 'lines' lines of the given file in the response, compressed on the fly with the best encoding accepted by the client
+func ServeTailFile(ctx *fasthttp.RequestCtx, filename string, lines int, opts *HTTPCompressOptions) error {
+	return serveTextCompressed(ctx, opts, func(encoding string, compressOpts *CompressOptions) error {
+		return TailFileCompressTo(ctx, filename, lines, encoding, compressOpts)
+	})
+}
+
+// ServeFilterFile write the lines of the file that contain "toFilter" (the ones returned by FilterFromFile) in the response,
+// compressed on the fly with the best encoding accepted by the client
+func ServeFilterFile(ctx *fasthttp.RequestCtx, filename string, maxLinesToSearch int, toFilter string, reverse bool, opts *HTTPCompressOptions) error {
+	return serveTextCompressed(ctx, opts, func(encoding string, compressOpts *CompressOptions) error {
+		return FilterFromFileCompressTo(ctx, filename, maxLinesToSearch, toFilter, reverse, encoding, compressOpts)
+	})
+}
+
+// serveTextCompressed negotiate the encoding and call write for compress the text in the response
+func serveTextCompressed(ctx *fasthttp.RequestCtx, opts *HTTPCompressOptions, write func(encoding string, compressOpts *CompressOptions) error) error {
+	ctx.SetContentType("text/plain; charset=utf-8")
+	encoding := negotiateResponseEncoding(ctx, opts)
+	if encoding == "" {
+		ctx.SetStatusCode(fasthttp.StatusNotAcceptable)
+		return ErrUnknownCompression
+	}
+	ctx.ResetBody()
+	if err := write(encoding, &CompressOptions{Level: opts.level()}); err != nil {
+		ctx.ResetBody()
+		return err
+	}
+	if encoding != CompressionIdentity {
+		ctx.Response.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestCompressHandlerFileServer(t *testing.T) {
+	server, root := newTestFileServer(t, nil)
+	defer os.RemoveAll(root)
+	content := strings.Repeat("a compressible line of text\n", 1000)
+	if err := ioutil.WriteFile(filepath.Join(root, "big.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler := CompressHandler(server.Handle, nil)
+	request := func(setupRequest func(*fasthttp.Request)) *fasthttp.RequestCtx {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/big.txt")
+		ctx.Request.Header.Set(fasthttp.HeaderAcceptEncoding, CompressionGzip)
+		if setupRequest != nil {
+			setupRequest(&ctx.Request)
+		}
+		handler(&ctx)
+		return &ctx
+	}
+
+	// The full response is compressed, the ETag become weak
+	ctx := request(nil)
+	if encoding := string(ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding)); encoding != CompressionGzip {
+		t.Fatalf("Content-Encoding = %q, expected gzip", encoding)
+	}
+	etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag))
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("ETag of the encoded response = %q, expected a weak ETag", etag)
+	}
+	var decompressed bytes.Buffer
+	if _, err := DecompressStream(&decompressed, bytes.NewReader(ctx.Response.Body()), CompressionGzip); err != nil || decompressed.String() != content {
+		t.Errorf("invalid compressed body: %v", err)
+	}
+
+	// The weak ETag is still valid for the conditional requests
+	ctx = request(func(req *fasthttp.Request) { req.Header.Set(fasthttp.HeaderIfNoneMatch, etag) })
+	if ctx.Response.StatusCode() != fasthttp.StatusNotModified {
+		t.Errorf("status with the weak ETag = %d, expected 304", ctx.Response.StatusCode())
+	}
+
+	// The partial responses are not compressed, the range refer to the identity body
+	ctx = request(func(req *fasthttp.Request) { req.Header.Set(fasthttp.HeaderRange, "bytes=0-9") })
+	if ctx.Response.StatusCode() != fasthttp.StatusPartialContent {
+		t.Fatalf("status = %d, expected 206", ctx.Response.StatusCode())
+	}
+	if encoding := ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding); len(encoding) != 0 {
+		t.Errorf("partial response compressed with %s", encoding)
+	}
+	if body := string(ctx.Response.Body()); body != content[:10] {
+		t.Errorf("partial body = %q, expected %q", body, content[:10])
+	}
+	if etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag)); strings.HasPrefix(etag, "W/") {
+		t.Errorf("ETag of the identity response = %q, expected a strong ETag", etag)
+	}
+}
+
+func TestCompressHandlerEncodedFile(t *testing.T) {
+	// A handler that serve the svgz as is, without the Content-Encoding header
+	content := []byte(strings.Repeat("<svg></svg>\n", 1000))
+	handler := CompressHandler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("image/svg+xml")
+		ctx.SetBody(content)
+	}, nil)
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/logo.svgz")
+	ctx.Request.Header.Set(fasthttp.HeaderAcceptEncoding, CompressionGzip)
+	handler(&ctx)
+	if encoding := ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding); len(encoding) != 0 {
+		t.Errorf("svgz compressed again with %s", encoding)
+	}
+	if !bytes.Equal(ctx.Response.Body(), content) {
+		t.Error("svgz body modified")
+	}
+}
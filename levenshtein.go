@@ -0,0 +1,46 @@
+package utils
+
+// Levenshtein return the edit distance (insertions, deletions and substitutions) between the two strings.
+// The strings are compared rune by rune, so the multi-byte characters count as a single edit.
+func Levenshtein(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 {
+		return len(rb)
+	}
+	if len(rb) == 0 {
+		return len(ra)
+	}
+	// Keep only the previous row of the matrix
+	cache := make([]int, len(ra)+1)
+	for i := range cache {
+		cache[i] = i
+	}
+	for j := 1; j <= len(rb); j++ {
+		prev := cache[0]
+		cache[0] = j
+		for i := 1; i <= len(ra); i++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current := cache[i]
+			cache[i] = min3(cache[i]+1, cache[i-1]+1, prev+cost)
+			prev = current
+		}
+	}
+	return cache[len(ra)]
+}
+
+// min3 return the minimum of the three integer
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// countBufferSize is the size of the chunk read for count the new lines
+const countBufferSize = 128 * 1024
+
+// countBufferPool avoid to allocate a new chunk for every count
+var countBufferPool = sync.Pool{New: func() interface{} {
+	buf := make([]byte, countBufferSize)
+	return &buf
+}}
+
+// CountLinesBytes return the number of new lines contained in data (like 'wc -l').
+// bytes.Count use the vectorized instructions of the CPU when available.
+func CountLinesBytes(data []byte) int64 {
+	return int64(bytes.Count(data, []byte{'\n'}))
+}
+
+// CountLinesReader return the number of new lines read from r, without allocate memory
+func CountLinesReader(r io.Reader) (int64, error) {
+	bufPtr := countBufferPool.Get().(*[]byte)
+	defer countBufferPool.Put(bufPtr)
+	buf := *bufPtr
+	var count int64
+	for {
+		n, err := r.Read(buf)
+		count += CountLinesBytes(buf[:n])
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// CountLinesRange return the number of new lines in the 'length' bytes of r starting from 'offset'
+func CountLinesRange(r io.ReaderAt, offset, length int64) (int64, error) {
+	return CountLinesReader(io.NewSectionReader(r, offset, length))
+}
+
+// CountFileLines return the number of new lines of the given file
+func CountFileLines(filename string) (int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+	return CountLinesReader(file)
+}
+
+// CountFileLinesParallel split the given file in 'workers' byte ranges and count the new lines of every range concurrently.
+// If workers is not positive, the number of CPU is used.
+func CountFileLinesParallel(filename string, workers int) (int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return -1, err
+	}
+	size := info.Size()
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	// Is not convenient to split the small files
+	if int64(workers) > size/countBufferSize {
+		workers = int(size/countBufferSize) + 1
+	}
+
+	var wg sync.WaitGroup
+	counts := make([]int64, workers)
+	errs := make([]error, workers)
+	chunk := size / int64(workers)
+	for i := 0; i < workers; i++ {
+		offset := int64(i) * chunk
+		length := chunk
+		if i == workers-1 { // The last worker count the remainder too
+			length = size - offset
+		}
+		wg.Add(1)
+		go func(i int, offset, length int64) {
+			defer wg.Done()
+			counts[i], errs[i] = CountLinesRange(file, offset, length)
+		}(i, offset, length)
+	}
+	wg.Wait()
+
+	var total int64
+	for i := range counts {
+		if errs[i] != nil {
+			return -1, errs[i]
+		}
+		total += counts[i]
+	}
+	return total, nil
+}
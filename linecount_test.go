@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// countLinesExec is the old implementation of CountLine, that run 'wc -l' in a shell
+func countLinesExec(filename string) (int64, error) {
+	stdout, err := exec.Command("/bin/sh", "-c", "wc -l \""+filename+"\"").Output()
+	if err != nil {
+		return -1, err
+	}
+	return strconv.ParseInt(strings.Fields(string(stdout))[0], 10, 64)
+}
+
+// writeLinesFile write the data in the file 'name' of the given directory, return the path
+func writeLinesFile(t testing.TB, dir, name string, data []byte) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// checkCountLines verify that all the count functions return the expected number of lines for the file
+func checkCountLines(t *testing.T, filename string, expected int64) {
+	if n, err := CountFileLines(filename); err != nil || n != expected {
+		t.Errorf("%s: CountFileLines = %d, %v, expected %d", filepath.Base(filename), n, err, expected)
+	}
+	for _, workers := range []int{0, 1, 2, 3, 4, 7} {
+		if n, err := CountFileLinesParallel(filename, workers); err != nil || n != expected {
+			t.Errorf("%s: CountFileLinesParallel(%d workers) = %d, %v, expected %d", filepath.Base(filename), workers, n, err, expected)
+		}
+	}
+	if n := CountLine(filename); n != int(expected) {
+		t.Errorf("%s: CountLine = %d, expected %d", filepath.Base(filename), n, expected)
+	}
+	if _, err := exec.LookPath("wc"); err == nil {
+		if n, err := countLinesExec(filename); err != nil || n != expected {
+			t.Errorf("%s: wc -l = %d, %v, expected %d", filepath.Base(filename), n, err, expected)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		data     []byte
+		expected int64
+	}{
+		{"empty", nil, 0},
+		{"only new line", []byte("\n"), 1},
+		// As 'wc -l', the last line without new line is not counted
+		{"no trailing new line", []byte("one\ntwo\nthree"), 2},
+		{"trailing new line", []byte("one\ntwo\nthree\n"), 3},
+		{"empty lines", []byte("\n\n\n"), 3},
+		{"crlf", []byte("one\r\ntwo\r\n"), 2},
+	}
+	for _, c := range cases {
+		if n := CountLinesBytes(c.data); n != c.expected {
+			t.Errorf("%s: CountLinesBytes = %d, expected %d", c.name, n, c.expected)
+		}
+		if n, err := CountLinesReader(bytes.NewReader(c.data)); err != nil || n != c.expected {
+			t.Errorf("%s: CountLinesReader = %d, %v, expected %d", c.name, n, err, c.expected)
+		}
+		checkCountLines(t, writeLinesFile(t, dir, strings.Replace(c.name, " ", "_", -1), c.data), c.expected)
+	}
+}
+
+func TestCountFileLinesParallelBoundaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// With 4 workers the file is split in chunks of countBufferSize bytes
+	const workers = 4
+	size := workers * countBufferSize
+
+	// Every chunk start and end with a new line: every range must count only its own new lines
+	data := bytes.Repeat([]byte{'a'}, size)
+	for i := 0; i < workers; i++ {
+		data[i*countBufferSize] = '\n'
+		data[(i+1)*countBufferSize-1] = '\n'
+	}
+	filename := writeLinesFile(t, dir, "boundaries", data)
+	if n, err := CountFileLinesParallel(filename, workers); err != nil || n != 2*workers {
+		t.Errorf("CountFileLinesParallel = %d, %v, expected %d", n, err, 2*workers)
+	}
+	checkCountLines(t, filename, 2*workers)
+
+	// Only new lines
+	checkCountLines(t, writeLinesFile(t, dir, "newlines", bytes.Repeat([]byte{'\n'}, size)), int64(size))
+
+	// Random lines, with a size that is not a multiple of the chunk
+	data = make([]byte, size+12345)
+	r := rand.New(rand.NewSource(1))
+	for i := range data {
+		if data[i] = 'a' + byte(r.Intn(26)); r.Intn(50) == 0 {
+			data[i] = '\n'
+		}
+	}
+	checkCountLines(t, writeLinesFile(t, dir, "random", data), CountLinesBytes(data))
+}
+
+func TestCountFileLinesMissing(t *testing.T) {
+	if n, err := CountFileLines(filepath.Join(os.TempDir(), "linecount-missing")); err == nil || n != -1 {
+		t.Errorf("CountFileLines = %d, %v, expected an error", n, err)
+	}
+	if n, err := CountFileLinesParallel(filepath.Join(os.TempDir(), "linecount-missing"), 2); err == nil || n != -1 {
+		t.Errorf("CountFileLinesParallel = %d, %v, expected an error", n, err)
+	}
+}
+
+func BenchmarkCountLines(b *testing.B) {
+	dir, err := ioutil.TempDir("", "linecount")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := bytes.Repeat([]byte("a line of a log file, not too short and not too long\n"), 1<<18)
+	filename := writeLinesFile(b, dir, "bench", data)
+	expected := CountLinesBytes(data)
+
+	benchmarks := []struct {
+		name  string
+		count func(string) (int64, error)
+	}{
+		{"CountFileLines", CountFileLines},
+		{"CountFileLinesParallel", func(filename string) (int64, error) { return CountFileLinesParallel(filename, 0) }},
+		{"wc", countLinesExec},
+	}
+	for _, bench := range benchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			if bench.name == "wc" {
+				if _, err := exec.LookPath("wc"); err != nil {
+					b.Skip("wc not available")
+				}
+			}
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if n, err := bench.count(filename); err != nil || n != expected {
+					b.Fatalf("%d lines, %v, expected %d", n, err, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+
+	"github.com/pierrec/lz4"
+)
+
+// lz4MaxRatio is the max compression ratio of a LZ4 block: a byte of a match length can represent at most 255 bytes.
+// It is used for reject the corrupted headers before allocate the output buffer.
+const lz4MaxRatio = 255
+
+// ErrLz4Corrupted is returned when the compressed data is not a valid LZ4 block or frame
+var ErrLz4Corrupted = errors.New("lz4: corrupted data")
+
+// lz4BlockBound return the max size of the decompressed data of a LZ4 block of the given size
+func lz4BlockBound(n int) int {
+	return n*lz4MaxRatio + 64
+}
+
+// lz4CompressRawBlock compress data as a LZ4 block without header, using the high compression algorithm when level is positive.
+// The incompressible data are stored as a single sequence of literals, so the result is always a valid LZ4 block.
+func lz4CompressRawBlock(data []byte, level int) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	compressed := make([]byte, lz4.CompressBlockBound(len(data)))
+	var n int
+	var err error
+	if level > 0 {
+		n, err = lz4.CompressBlockHC(data, compressed, level)
+	} else {
+		var ht [1 << 16]int
+		n, err = lz4.CompressBlock(data, compressed, ht[:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		n = lz4LiteralBlock(data, compressed)
+	}
+	return compressed[:n], nil
+}
+
+// lz4LiteralBlock write in dst a LZ4 block that contains only the literals of src, returning the size of the block.
+// dst must have at least lz4.CompressBlockBound(len(src)) bytes.
+func lz4LiteralBlock(src, dst []byte) int {
+	length := len(src)
+	i := 0
+	if length < 0xF {
+		dst[i] = byte(length << 4)
+	} else {
+		dst[i] = 0xF0
+		for length -= 0xF; length >= 0xFF; length -= 0xFF {
+			i++
+			dst[i] = 0xFF
+		}
+		i++
+		dst[i] = byte(length)
+	}
+	i++
+	return i + copy(dst[i:], src)
+}
+
+// lz4DecompressRawBlock decompress a LZ4 block without header.
+// The output buffer start from sizeHint (if positive) and grow until the data fit in it.
+func lz4DecompressRawBlock(block []byte, sizeHint int) ([]byte, error) {
+	if len(block) == 0 {
+		return []byte{}, nil
+	}
+	limit := lz4BlockBound(len(block))
+	size := sizeHint
+	if size <= 0 {
+		size = 4 * len(block)
+	}
+	if size > limit {
+		size = limit
+	}
+	for {
+		decompressed := make([]byte, size)
+		n, err := lz4.UncompressBlock(block, decompressed)
+		if err == nil {
+			return decompressed[:n], nil
+		}
+		if err != lz4.ErrInvalidSourceShortBuffer || size == limit {
+			return nil, ErrLz4Corrupted
+		}
+		if size *= 2; size > limit {
+			size = limit
+		}
+	}
+}
+
+// Lz4CompressBlock compress data as a single LZ4 block, preceded by the size of the original data (as uvarint).
+// level 0 use the fast algorithm, a positive level the high compression one (slower, with a better ratio).
+// The result can be decompressed with Lz4DecompressBlock.
+func Lz4CompressBlock(data []byte, level int) ([]byte, error) {
+	compressed, err := lz4CompressRawBlock(data, level)
+	if err != nil {
+		return nil, err
+	}
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(len(data)))
+	return append(header[:n:n], compressed...), nil
+}
+
+// Lz4DecompressBlock decompress a block created by Lz4CompressBlock.
+// The output buffer is allocated using the size in the header, an error is returned if the block is corrupted.
+func Lz4DecompressBlock(block []byte) ([]byte, error) {
+	size, n := binary.Uvarint(block)
+	if n <= 0 {
+		return nil, ErrLz4Corrupted
+	}
+	block = block[n:]
+	if size > uint64(lz4BlockBound(len(block))) {
+		return nil, ErrLz4Corrupted
+	}
+	if size == 0 {
+		if len(block) != 0 {
+			return nil, ErrLz4Corrupted
+		}
+		return []byte{}, nil
+	}
+	decompressed := make([]byte, size)
+	length, err := lz4.UncompressBlock(block, decompressed)
+	if err != nil || uint64(length) != size {
+		return nil, ErrLz4Corrupted
+	}
+	return decompressed, nil
+}
+
+// Lz4CompressFrame compress data in the LZ4 frame format (the one of the lz4 command line tool), with the size of the data in the header.
+// level 0 is the fastest compression.
+func Lz4CompressFrame(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := lz4.NewWriter(&buf)
+	writer.Header.CompressionLevel = level
+	writer.Header.Size = uint64(len(data))
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lz4MinFrameSize is the size of the smallest frame header: magic number (4 bytes), flags, block descriptor and checksum
+const lz4MinFrameSize = 7
+
+// Lz4DecompressFrame decompress a LZ4 frame, like the ones created by Lz4CompressFrame or by the lz4 command line tool.
+// ErrLz4Corrupted is returned if the frame is truncated or corrupted.
+func Lz4DecompressFrame(frame []byte) (decompressed []byte, err error) {
+	// The reader of the lz4 library return no error for a truncated header
+	if len(frame) < lz4MinFrameSize {
+		return nil, ErrLz4Corrupted
+	}
+	// The reader of the lz4 library can panic on some corrupted frames
+	defer func() {
+		if recover() != nil {
+			decompressed, err = nil, ErrLz4Corrupted
+		}
+	}()
+	if decompressed, err = ioutil.ReadAll(lz4.NewReader(bytes.NewReader(frame))); err != nil {
+		return nil, ErrLz4Corrupted
+	}
+	return decompressed, nil
+}
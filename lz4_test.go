@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/pierrec/lz4"
+)
+
+// lz4Levels are the levels tested: the fast algorithm and the high compression one
+var lz4Levels = []int{0, 9}
+
+// compressibleData return n bytes of text with a lot of repetitions
+func compressibleData(n int) []byte {
+	return bytes.Repeat([]byte("compressible data "), n/18+1)[:n]
+}
+
+// randomData return n random (incompressible) bytes
+func randomData(r *rand.Rand, n int) []byte {
+	data := make([]byte, n)
+	r.Read(data)
+	return data
+}
+
+// checkLz4RoundTrip compress and decompress the data with both the block and the frame format
+func checkLz4RoundTrip(t *testing.T, data []byte) {
+	for _, level := range lz4Levels {
+		block, err := Lz4CompressBlock(data, level)
+		if err != nil {
+			t.Fatalf("Lz4CompressBlock(%d bytes, level %d): %v", len(data), level, err)
+		}
+		decompressed, err := Lz4DecompressBlock(block)
+		if err != nil {
+			t.Fatalf("Lz4DecompressBlock(%d bytes, level %d): %v", len(data), level, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("block round trip mismatch for %d bytes, level %d", len(data), level)
+		}
+
+		frame, err := Lz4CompressFrame(data, level)
+		if err != nil {
+			t.Fatalf("Lz4CompressFrame(%d bytes, level %d): %v", len(data), level, err)
+		}
+		if decompressed, err = Lz4DecompressFrame(frame); err != nil {
+			t.Fatalf("Lz4DecompressFrame(%d bytes, level %d): %v", len(data), level, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("frame round trip mismatch for %d bytes, level %d", len(data), level)
+		}
+	}
+}
+
+func TestLz4RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, 14, 15, 16, 269, 270, 271, 524, 525, 4096, 1 << 20} {
+		checkLz4RoundTrip(t, randomData(r, size))
+		checkLz4RoundTrip(t, compressibleData(size))
+	}
+}
+
+func TestLz4RoundTripQuick(t *testing.T) {
+	check := func(data []byte, repeat uint8) bool {
+		// Mix the random data with repetitions of the same data, in order to have both literals and matches
+		data = append(data, bytes.Repeat(data, int(repeat%8))...)
+		for _, level := range lz4Levels {
+			block, err := Lz4CompressBlock(data, level)
+			if err != nil {
+				return false
+			}
+			if decompressed, err := Lz4DecompressBlock(block); err != nil || !bytes.Equal(decompressed, data) {
+				return false
+			}
+			frame, err := Lz4CompressFrame(data, level)
+			if err != nil {
+				return false
+			}
+			if decompressed, err := Lz4DecompressFrame(frame); err != nil || !bytes.Equal(decompressed, data) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLz4CompressionRatio(t *testing.T) {
+	data := compressibleData(1 << 16)
+	for _, level := range lz4Levels {
+		block, err := Lz4CompressBlock(data, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block) > len(data)/10 {
+			t.Errorf("level %d: compressible data not compressed, %d bytes from %d", level, len(block), len(data))
+		}
+	}
+	// The incompressible data are stored as literals, with a small overhead
+	data = randomData(rand.New(rand.NewSource(2)), 1<<16)
+	block, err := Lz4CompressBlock(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block) > lz4.CompressBlockBound(len(data))+binary.MaxVarintLen64 {
+		t.Errorf("incompressible data: %d bytes from %d", len(block), len(data))
+	}
+}
+
+func TestLz4LiteralBlock(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	// The length of the literals use extra bytes from 15 bytes, and a 0xFF byte for every other 255 bytes (from 270)
+	for _, size := range []int{1, 14, 15, 16, 269, 270, 271, 524, 525, 526, 70000} {
+		src := randomData(r, size)
+		dst := make([]byte, lz4.CompressBlockBound(size))
+		n := lz4LiteralBlock(src, dst)
+		if expected := 1 + (size+255-15)/255 + size; size < 15 && n != 1+size || size >= 15 && n != expected {
+			t.Errorf("size %d: unexpected block size %d", size, n)
+		}
+		decompressed, err := lz4DecompressRawBlock(dst[:n], size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(decompressed, src) {
+			t.Errorf("size %d: literal block mismatch", size)
+		}
+	}
+}
+
+func TestLz4DecompressBlockCorrupted(t *testing.T) {
+	data := compressibleData(4096)
+	block, err := Lz4CompressBlock(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header [binary.MaxVarintLen64]byte
+	withSize := func(size uint64) []byte {
+		n := binary.PutUvarint(header[:], size)
+		return append(append([]byte{}, header[:n]...), block[binary.PutUvarint(header[:], uint64(len(data))):]...)
+	}
+	cases := map[string][]byte{
+		"empty":             {},
+		"truncated header":  {0x80},
+		"header overflow":   bytes.Repeat([]byte{0xFF}, binary.MaxVarintLen64+1),
+		"huge size":         withSize(1 << 40),
+		"size bigger":       withSize(uint64(len(data) + 1)),
+		"size smaller":      withSize(uint64(len(data) - 1)),
+		"zero size":         withSize(0),
+		"truncated block":   block[:len(block)/2],
+		"invalid offset":    append(append([]byte{}, block[:2]...), 0x1F, 'a', 0xFF, 0xFF),
+		"only header":       block[:binary.PutUvarint(header[:], uint64(len(data)))],
+		"random after size": append(withSize(100)[:1], randomData(rand.New(rand.NewSource(4)), 32)...),
+	}
+	for name, corrupted := range cases {
+		if _, err := Lz4DecompressBlock(corrupted); err != ErrLz4Corrupted {
+			t.Errorf("%s: expected ErrLz4Corrupted, got %v", name, err)
+		}
+	}
+}
+
+func TestLz4DecompressFrameCorrupted(t *testing.T) {
+	frame, err := Lz4CompressFrame(compressibleData(4096), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]byte{
+		"empty":            {},
+		"only magic":       frame[:4],
+		"truncated header": frame[:6],
+		"invalid magic":    append([]byte{0, 0, 0, 0}, frame[4:]...),
+		"truncated":        frame[:len(frame)/2],
+		"no end mark":      frame[:len(frame)-1],
+	}
+	for name, corrupted := range cases {
+		if _, err := Lz4DecompressFrame(corrupted); err != ErrLz4Corrupted {
+			t.Errorf("%s: expected ErrLz4Corrupted, got %v", name, err)
+		}
+	}
+}
+
+func TestLz4DecompressRandomQuick(t *testing.T) {
+	// The random data must be rejected (or decompressed) without panic
+	check := func(data []byte) bool {
+		if decompressed, err := Lz4DecompressBlock(data); err != nil && (err != ErrLz4Corrupted || decompressed != nil) {
+			return false
+		}
+		frame := append([]byte{0x04, 0x22, 0x4D, 0x18}, data...)
+		if decompressed, err := Lz4DecompressFrame(frame); err != nil && (err != ErrLz4Corrupted || decompressed != nil) {
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMemoryCheckInterval is the interval of the checks of the MemoryManager when not specified
+const DefaultMemoryCheckInterval = time.Minute
+
+// ErrMemoryManagerRunning is returned by Start when the MemoryManager is already running
+var ErrMemoryManagerRunning = errors.New("memory manager already running")
+
+// MemoryManagerOptions contains the parameters of the MemoryManager
+type MemoryManagerOptions struct {
+	// Interval between the checks, DefaultMemoryCheckInterval if 0. Can be changed with SetInterval.
+	Interval time.Duration
+	// Delay before the first check
+	Delay time.Duration
+	// SoftLimit is the size in bytes of the heap (HeapAlloc) over which debug.FreeOSMemory is called, 0 for disable.
+	// NOTE: if the live data are over the limit, the memory is freed at every check
+	SoftLimit uint64
+	// Quiet disable the log of the memory usage at every check
+	Quiet bool
+	// Callback is called after every check with the snapshot of the runtime, freed is true if FreeOSMemory was called
+	Callback func(snapshot *RuntimeSnapshot, freed bool)
+}
+
+// MemoryManager check periodically the memory used by the process, logging the usage and returning
+// the memory to the operating system when the heap cross the soft limit
+type MemoryManager struct {
+	opts MemoryManagerOptions
+	// interval is the interval in nanoseconds, updated atomically
+	interval int64
+	// reset wake up the loop when the interval change
+	reset chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMemoryManager return a MemoryManager, opts can be nil
+func NewMemoryManager(opts *MemoryManagerOptions) *MemoryManager {
+	if opts == nil {
+		opts = &MemoryManagerOptions{}
+	}
+	m := &MemoryManager{opts: *opts, reset: make(chan struct{}, 1)}
+	m.SetInterval(opts.Interval)
+	return m
+}
+
+// Interval return the current interval between the checks
+func (m *MemoryManager) Interval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&m.interval))
+}
+
+// SetInterval change the interval between the checks, also while the manager is running. 0 set the default.
+func (m *MemoryManager) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultMemoryCheckInterval
+	}
+	atomic.StoreInt64(&m.interval, int64(interval))
+	select {
+	case m.reset <- struct{}{}:
+	default:
+	}
+}
+
+// Run check the memory until the context is done, return the context error
+func (m *MemoryManager) Run(ctx context.Context) error {
+	if m.opts.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.opts.Delay):
+		}
+	}
+	// Drain the reset of the initial SetInterval
+	select {
+	case <-m.reset:
+	default:
+	}
+	m.Check()
+	timer := time.NewTimer(m.Interval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.reset:
+			// The interval is changed, restart the timer with the new value without check the memory
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+			m.Check()
+		}
+		timer.Reset(m.Interval())
+	}
+}
+
+// Start run the manager in a new goroutine, until Stop is called or the context is done
+func (m *MemoryManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return ErrMemoryManagerRunning
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	m.cancel, m.done = cancel, done
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	return nil
+}
+
+// Stop stop the manager started with Start and wait the end of the goroutine
+func (m *MemoryManager) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Check log the memory usage and free the memory if the heap is over the soft limit, return the snapshot of the runtime
+func (m *MemoryManager) Check() *RuntimeSnapshot {
+	snapshot := ReadRuntimeSnapshot()
+	if !m.opts.Quiet {
+		log.Info("MemoryManager | " + formatMemUsage(snapshot.Alloc, snapshot.TotalAlloc, snapshot.Sys, snapshot.NumGC, snapshot.GCPauses.Total))
+	}
+	freed := false
+	if m.opts.SoftLimit > 0 && snapshot.HeapAlloc > m.opts.SoftLimit {
+		released := snapshot.HeapReleased
+		debug.FreeOSMemory()
+		freed = true
+		var rtm runtime.MemStats
+		runtime.ReadMemStats(&rtm)
+		log.Info("MemoryManager | Heap [", bToMb(snapshot.HeapAlloc), "MiB] over the soft limit [", bToMb(m.opts.SoftLimit),
+			"MiB] | Memory freed! | Heap = ", bToMb(rtm.HeapAlloc), "MiB\tReleased = ", bToMb(rtm.HeapReleased-minUint64(released, rtm.HeapReleased)), "MiB")
+	}
+	if m.opts.Callback != nil {
+		m.opts.Callback(snapshot, freed)
+	}
+	return snapshot
+}
+
+// formatMemUsage return the description of the memory used
+func formatMemUsage(alloc, totalAlloc, sys uint64, numGC uint32, pauseTotal time.Duration) string {
+	return "Alloc = " + bToMb(alloc) + "MiB\tTotalAlloc = " + bToMb(totalAlloc) +
+		"MiB\tSys = " + bToMb(sys) + "MiB\tNumGC = " + strconv.FormatInt(int64(numGC), 10) + "\tPausGCTotal = " + pauseTotal.String()
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,554 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// Content types of the expositions of the MetricsRegistry
+const (
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	PrometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Types of the metrics
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// DefaultHistogramBuckets are the upper bounds of the buckets used when a histogram is created without buckets,
+// suitable for the duration of the requests in seconds
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Labels are the labels (name -> value) that identify a series of a metric
+type Labels map[string]string
+
+// atomicFloat is a float64 updated atomically
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+func (f *atomicFloat) store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		if atomic.CompareAndSwapUint64(&f.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Counter is a metric that can only increase, ex: the number of requests served
+type Counter struct {
+	value atomicFloat
+}
+
+// Inc increment the counter by 1
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add increment the counter by the given value, the negative values are ignored
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		c.value.add(v)
+	}
+}
+
+// Value return the current value of the counter
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+// Gauge is a metric that can increase and decrease, ex: the number of connections open
+type Gauge struct {
+	value atomicFloat
+}
+
+// Set set the gauge to the given value
+func (g *Gauge) Set(v float64) {
+	g.value.store(v)
+}
+
+// Add add the given value (also negative) to the gauge
+func (g *Gauge) Add(v float64) {
+	g.value.add(v)
+}
+
+// Inc increment the gauge by 1
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+// Dec decrement the gauge by 1
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+// Value return the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+// Histogram count the observed values in buckets, ex: the duration of the requests
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe add the given value to the histogram
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// snapshot return the cumulative counts of the buckets, the count and the sum of the observations
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, c := range h.counts {
+		total += c
+		cumulative[i] = total
+	}
+	return cumulative, h.count, h.sum
+}
+
+// metricSeries is a series of a family, identified by the labels
+type metricSeries struct {
+	labels    Labels
+	key       string
+	counter   *Counter
+	gauge     *Gauge
+	histogram *Histogram
+}
+
+// metricFamily contains all the series of a metric with the same name
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// MetricsRegistry contains the metrics exposed in the OpenMetrics (Prometheus) text format or in JSON.
+// The metrics are created on the first use of the name and labels, and returned by the following calls.
+type MetricsRegistry struct {
+	mu         sync.RWMutex
+	families   map[string]*metricFamily
+	collectors []func(*MetricsRegistry)
+}
+
+// NewMetricsRegistry return an empty registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{families: make(map[string]*metricFamily)}
+}
+
+// DefaultMetrics is the registry used by MetricsHandler, it contains the runtime, process and host metrics
+// (see RegisterRuntimeMetrics and RegisterProcessMetrics)
+var DefaultMetrics = NewMetricsRegistry().RegisterRuntimeMetrics().RegisterProcessMetrics()
+
+// labelsKey return the key of the labels, validating the names
+func labelsKey(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if !labelNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			panic(fmt.Sprintf("metrics: invalid label name [%s]", name))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(0)
+		sb.WriteString(labels[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// series return the series of the given metric, creating it if not present.
+// Panic if the name or the labels are not valid, or if the name is already used by a metric of a different type.
+func (r *MetricsRegistry) series(name, help, kind string, buckets []float64, labels Labels) *metricSeries {
+	if !metricNameRegexp.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name [%s]", name))
+	}
+	key := labelsKey(labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[name]
+	if !ok {
+		family = &metricFamily{name: name, help: help, kind: kind, buckets: buckets, series: make(map[string]*metricSeries)}
+		r.families[name] = family
+	} else if family.kind != kind {
+		panic(fmt.Sprintf("metrics: metric [%s] already registered as %s", name, family.kind))
+	}
+	s, ok := family.series[key]
+	if !ok {
+		// The labels are copied, the map of the caller can be reused
+		copied := make(Labels, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &metricSeries{labels: copied, key: key}
+		switch kind {
+		case MetricCounter:
+			s.counter = &Counter{}
+		case MetricGauge:
+			s.gauge = &Gauge{}
+		case MetricHistogram:
+			s.histogram = &Histogram{buckets: family.buckets, counts: make([]uint64, len(family.buckets))}
+		}
+		family.series[key] = s
+	}
+	return s
+}
+
+// Counter return the counter with the given name and labels (can be nil). The "_total" suffix is added in the exposition.
+func (r *MetricsRegistry) Counter(name, help string, labels Labels) *Counter {
+	return r.series(strings.TrimSuffix(name, "_total"), help, MetricCounter, nil, labels).counter
+}
+
+// Gauge return the gauge with the given name and labels (can be nil)
+func (r *MetricsRegistry) Gauge(name, help string, labels Labels) *Gauge {
+	return r.series(name, help, MetricGauge, nil, labels).gauge
+}
+
+// Histogram return the histogram with the given name and labels (can be nil). The buckets are the upper bounds,
+// DefaultHistogramBuckets if empty; they are used only by the first call for the name.
+func (r *MetricsRegistry) Histogram(name, help string, buckets []float64, labels Labels) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	sorted := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		// The +Inf bucket is always present
+		if !math.IsInf(b, 1) {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Float64s(sorted)
+	return r.series(name, help, MetricHistogram, sorted, labels).histogram
+}
+
+// RegisterCollector register a function called before every exposition, used to update the metrics
+// that are read from another source (ex: the runtime)
+func (r *MetricsRegistry) RegisterCollector(collector func(*MetricsRegistry)) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, collector)
+	r.mu.Unlock()
+}
+
+// collect call the collectors
+func (r *MetricsRegistry) collect() {
+	r.mu.RLock()
+	collectors := r.collectors
+	r.mu.RUnlock()
+	for _, collector := range collectors {
+		collector(r)
+	}
+}
+
+// ReadMonitor return the current stats of the runtime
+func ReadMonitor() Monitor {
+	var rtm runtime.MemStats
+	runtime.ReadMemStats(&rtm)
+	return monitorFromMemStats(&rtm)
+}
+
+// RegisterRuntimeMetrics register a collector that expose the fields of the Monitor, return the registry
+func (r *MetricsRegistry) RegisterRuntimeMetrics() *MetricsRegistry {
+	goroutines := r.Gauge("go_goroutines", "Number of goroutines that currently exist.", nil)
+	alloc := r.Gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", nil)
+	totalAlloc := r.Counter("go_memstats_allocated_bytes_total", "Total number of bytes allocated, even if freed.", nil)
+	sys := r.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", nil)
+	mallocs := r.Counter("go_memstats_mallocs_total", "Total number of mallocs.", nil)
+	frees := r.Counter("go_memstats_frees_total", "Total number of frees.", nil)
+	liveObjects := r.Gauge("go_memstats_live_objects", "Number of allocated objects not yet freed.", nil)
+	mcache := r.Gauge("go_memstats_mcache_inuse_bytes", "Number of bytes in use by mcache structures.", nil)
+	pause := r.Counter("go_gc_pause_seconds_total", "Total time spent in the stop-the-world pauses of the GC.", nil)
+	numGC := r.Counter("go_gc_cycles_total", "Number of completed GC cycles.", nil)
+	cpuFraction := r.Gauge("go_gc_cpu_fraction", "Fraction of the CPU time used by the GC since the program started.", nil)
+	r.RegisterCollector(func(*MetricsRegistry) {
+		m := ReadMonitor()
+		goroutines.Set(float64(m.NumGoroutine))
+		alloc.Set(float64(m.Alloc))
+		totalAlloc.value.store(float64(m.TotalAlloc))
+		sys.Set(float64(m.Sys))
+		mallocs.value.store(float64(m.Mallocs))
+		frees.value.store(float64(m.Frees))
+		liveObjects.Set(float64(m.LiveObjects))
+		mcache.Set(float64(m.MCacheInuse))
+		pause.value.store(float64(m.PauseTotalNs) / 1e9)
+		numGC.value.store(float64(m.NumGC))
+		cpuFraction.Set(m.GCCPUFraction)
+	})
+	return r
+}
+
+// RegisterProcessMetrics register a collector that expose the stats of the process and of the host read from the
+// DefaultProcFS (see ReadProcessStats and ReadHostStats), return the registry. Nothing is exposed if the stats are not available.
+func (r *MetricsRegistry) RegisterProcessMetrics() *MetricsRegistry {
+	r.RegisterCollector(func(r *MetricsRegistry) {
+		if process, err := ReadProcessStats(); err == nil {
+			r.Gauge("process_resident_memory_bytes", "Resident memory size in bytes.", nil).Set(float64(process.RSS))
+			r.Gauge("process_virtual_memory_bytes", "Virtual memory size in bytes.", nil).Set(float64(process.VirtualMemory))
+			r.Gauge("process_open_fds", "Number of open file descriptors.", nil).Set(float64(process.OpenFDs))
+			r.Gauge("process_max_fds", "Maximum number of open file descriptors.", nil).Set(float64(process.MaxFDs))
+			r.Gauge("process_threads", "Number of OS threads of the process.", nil).Set(float64(process.Threads))
+			r.Counter("process_cpu_seconds_total", "Total user and system CPU time spent in seconds.", nil).value.store((process.CPUUser + process.CPUSystem).Seconds())
+			r.Counter("process_io_read_bytes_total", "Total bytes read from the storage.", nil).value.store(float64(process.ReadBytes))
+			r.Counter("process_io_write_bytes_total", "Total bytes written to the storage.", nil).value.store(float64(process.WriteBytes))
+		}
+		host, err := ReadHostStats(DefaultProcFS.DiskPaths...)
+		if err != nil {
+			return
+		}
+		r.Gauge("node_load1", "1m load average.", nil).Set(host.Load1)
+		r.Gauge("node_load5", "5m load average.", nil).Set(host.Load5)
+		r.Gauge("node_load15", "15m load average.", nil).Set(host.Load15)
+		r.Gauge("node_memory_total_bytes", "Total memory of the host in bytes.", nil).Set(float64(host.MemTotal))
+		r.Gauge("node_memory_available_bytes", "Memory available for the new applications in bytes.", nil).Set(float64(host.MemAvailable))
+		r.Gauge("node_memory_free_bytes", "Free memory of the host in bytes.", nil).Set(float64(host.MemFree))
+		for _, disk := range host.Disks {
+			labels := Labels{"path": disk.Path}
+			r.Gauge("node_filesystem_size_bytes", "Filesystem size in bytes.", labels).Set(float64(disk.Total))
+			r.Gauge("node_filesystem_avail_bytes", "Filesystem space available to non-root users in bytes.", labels).Set(float64(disk.Available))
+			r.Gauge("node_filesystem_free_bytes", "Filesystem free space in bytes.", labels).Set(float64(disk.Free))
+		}
+	})
+	return r
+}
+
+// sortedFamilies return the families ordered by name, and the series of every family ordered by labels
+func (r *MetricsRegistry) sortedFamilies() ([]*metricFamily, map[string][]*metricSeries) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	families := make([]*metricFamily, 0, len(r.families))
+	series := make(map[string][]*metricSeries, len(r.families))
+	for _, family := range r.families {
+		families = append(families, family)
+		list := make([]*metricSeries, 0, len(family.series))
+		for _, s := range family.series {
+			list = append(list, s)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].key < list[j].key })
+		series[family.name] = list
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return families, series
+}
+
+// formatMetricValue format the value as expected by the text format
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// writeSample write a line of the exposition, extra is an additional label (ex: le for the buckets)
+func writeSample(w *bufio.Writer, name string, labels Labels, extraName, extraValue, value string) {
+	w.WriteString(name)
+	names := make([]string, 0, len(labels))
+	for labelName := range labels {
+		names = append(names, labelName)
+	}
+	sort.Strings(names)
+	if len(names) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, labelName := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labelName + `="` + labelValueEscaper.Replace(labels[labelName]) + `"`)
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + value + "\n")
+}
+
+// WriteText write the metrics in the OpenMetrics text format, or in the Prometheus text format (version 0.0.4) if openMetrics is false
+func (r *MetricsRegistry) WriteText(w io.Writer, openMetrics bool) error {
+	r.collect()
+	families, series := r.sortedFamilies()
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		name := family.name
+		// In the Prometheus format the name of the family of a counter contains the suffix
+		if family.kind == MetricCounter && !openMetrics {
+			name += "_total"
+		}
+		if family.help != "" {
+			bw.WriteString("# HELP " + name + " " + helpEscaper.Replace(family.help) + "\n")
+		}
+		bw.WriteString("# TYPE " + name + " " + family.kind + "\n")
+		for _, s := range series[family.name] {
+			switch family.kind {
+			case MetricCounter:
+				writeSample(bw, family.name+"_total", s.labels, "", "", formatMetricValue(s.counter.Value()))
+			case MetricGauge:
+				writeSample(bw, family.name, s.labels, "", "", formatMetricValue(s.gauge.Value()))
+			case MetricHistogram:
+				counts, count, sum := s.histogram.snapshot()
+				for i, bound := range family.buckets {
+					writeSample(bw, family.name+"_bucket", s.labels, "le", formatMetricValue(bound), strconv.FormatUint(counts[i], 10))
+				}
+				writeSample(bw, family.name+"_bucket", s.labels, "le", "+Inf", strconv.FormatUint(count, 10))
+				writeSample(bw, family.name+"_sum", s.labels, "", "", formatMetricValue(sum))
+				writeSample(bw, family.name+"_count", s.labels, "", "", strconv.FormatUint(count, 10))
+			}
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// jsonSeries is the JSON encoding of a series
+type jsonSeries struct {
+	Labels  Labels            `json:"labels,omitempty"`
+	Value   *float64          `json:"value,omitempty"`
+	Count   *uint64           `json:"count,omitempty"`
+	Sum     *float64          `json:"sum,omitempty"`
+	Buckets map[string]uint64 `json:"buckets,omitempty"`
+}
+
+// jsonFamily is the JSON encoding of a family
+type jsonFamily struct {
+	Type   string       `json:"type"`
+	Help   string       `json:"help,omitempty"`
+	Series []jsonSeries `json:"series"`
+}
+
+// jsonFloat return a pointer to the value, NaN and Inf (not supported by JSON) are returned as nil
+func jsonFloat(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// JSON return the metrics encoded in JSON, the object contains a key for every metric with the type, the help and the series
+func (r *MetricsRegistry) JSON() ([]byte, error) {
+	r.collect()
+	families, series := r.sortedFamilies()
+	out := make(map[string]jsonFamily, len(families))
+	for _, family := range families {
+		encoded := jsonFamily{Type: family.kind, Help: family.help, Series: make([]jsonSeries, 0, len(series[family.name]))}
+		for _, s := range series[family.name] {
+			item := jsonSeries{Labels: s.labels}
+			switch family.kind {
+			case MetricCounter:
+				item.Value = jsonFloat(s.counter.Value())
+			case MetricGauge:
+				item.Value = jsonFloat(s.gauge.Value())
+			case MetricHistogram:
+				counts, count, sum := s.histogram.snapshot()
+				item.Count, item.Sum = &count, jsonFloat(sum)
+				item.Buckets = make(map[string]uint64, len(counts)+1)
+				for i, bound := range family.buckets {
+					item.Buckets[formatMetricValue(bound)] = counts[i]
+				}
+				item.Buckets["+Inf"] = count
+			}
+			encoded.Series = append(encoded.Series, item)
+		}
+		out[family.name] = encoded
+	}
+	return json.Marshal(out)
+}
+
+// Handler return a fasthttp handler that expose the metrics (ex: on /metrics). The format is choosen from the Accept header:
+// OpenMetrics for "application/openmetrics-text", JSON for "application/json" (or the "format=json" argument), Prometheus text for the others.
+func (r *MetricsRegistry) Handler() fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		accept := string(ctx.Request.Header.Peek(fasthttp.HeaderAccept))
+		if string(ctx.QueryArgs().Peek("format")) == "json" || (strings.Contains(accept, "application/json") && !strings.Contains(accept, "openmetrics")) {
+			data, err := r.JSON()
+			if err != nil {
+				log.Error("MetricsHandler | Unable to encode the metrics | ERR: ", err)
+				ctx.Error("Internal server error", fasthttp.StatusInternalServerError)
+				return
+			}
+			ctx.SetContentType("application/json")
+			ctx.SetBody(data)
+			return
+		}
+		openMetrics := strings.Contains(accept, "application/openmetrics-text")
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf, openMetrics); err != nil {
+			log.Error("MetricsHandler | Unable to encode the metrics | ERR: ", err)
+			ctx.Error("Internal server error", fasthttp.StatusInternalServerError)
+			return
+		}
+		if openMetrics {
+			ctx.SetContentType(OpenMetricsContentType)
+		} else {
+			ctx.SetContentType(PrometheusContentType)
+		}
+		ctx.SetBody(buf.Bytes())
+	}
+}
+
+// MetricsHandler return the fasthttp handler of the DefaultMetrics
+func MetricsHandler() fasthttp.RequestHandler {
+	return DefaultMetrics.Handler()
+}
@@ -0,0 +1,453 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Values of the disposition type of the Content-Disposition header
+const (
+	DispositionInline     = "inline"
+	DispositionAttachment = "attachment"
+)
+
+// DefaultMimeType is the MIME type of the unknown data
+const DefaultMimeType = "application/octet-stream"
+
+// mimeSniffLen is the number of byte of the content used for recognize the type, the one used by http.DetectContentType
+const mimeSniffLen = 512
+
+// mimeTypes contains the MIME types by extension (lowercase, without the dot)
+var mimeTypes = map[string]string{
+	// Documents
+	"pdf":  "application/pdf",
+	"doc":  "application/msword",
+	"dot":  "application/msword",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"dotx": "application/vnd.openxmlformats-officedocument.wordprocessingml.template",
+	"xls":  "application/vnd.ms-excel",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"ppt":  "application/vnd.ms-powerpoint",
+	"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"odt":  "application/vnd.oasis.opendocument.text",
+	"ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	"odp":  "application/vnd.oasis.opendocument.presentation",
+	"rtf":  "application/rtf",
+	"epub": "application/epub+zip",
+	"msg":  "application/vnd.ms-outlook",
+	"ps":   "application/postscript",
+	"eps":  "application/postscript",
+	// Text
+	"txt":      "text/plain",
+	"text":     "text/plain",
+	"log":      "text/plain",
+	"conf":     "text/plain",
+	"ini":      "text/plain",
+	"md":       "text/markdown",
+	"markdown": "text/markdown",
+	"csv":      "text/csv",
+	"tsv":      "text/tab-separated-values",
+	"html":     "text/html",
+	"htm":      "text/html",
+	"css":      "text/css",
+	"js":       "text/javascript",
+	"mjs":      "text/javascript",
+	"xml":      "text/xml",
+	"ics":      "text/calendar",
+	"vcf":      "text/vcard",
+	"yaml":     "application/yaml",
+	"yml":      "application/yaml",
+	"toml":     "application/toml",
+	"json":     "application/json",
+	"map":      "application/json",
+	"jsonld":   "application/ld+json",
+	"xhtml":    "application/xhtml+xml",
+	"rss":      "application/rss+xml",
+	"atom":     "application/atom+xml",
+	"sh":       "application/x-sh",
+	"sql":      "application/sql",
+	"go":       "text/x-go",
+	"c":        "text/x-c",
+	"h":        "text/x-c",
+	"py":       "text/x-python",
+	"java":     "text/x-java-source",
+	// Images
+	"png":  "image/png",
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"jpe":  "image/jpeg",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"bmp":  "image/bmp",
+	"ico":  "image/x-icon",
+	"svg":  "image/svg+xml",
+	"svgz": "image/svg+xml",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"avif": "image/avif",
+	"heic": "image/heic",
+	"psd":  "image/vnd.adobe.photoshop",
+	// Audio
+	"mp3":  "audio/mpeg",
+	"wav":  "audio/wav",
+	"ogg":  "audio/ogg",
+	"oga":  "audio/ogg",
+	"opus": "audio/opus",
+	"flac": "audio/flac",
+	"aac":  "audio/aac",
+	"m4a":  "audio/mp4",
+	"mid":  "audio/midi",
+	"midi": "audio/midi",
+	"weba": "audio/webm",
+	// Video
+	"mp4":  "video/mp4",
+	"m4v":  "video/mp4",
+	"mov":  "video/quicktime",
+	"webm": "video/webm",
+	"mkv":  "video/x-matroska",
+	"avi":  "video/x-msvideo",
+	"ogv":  "video/ogg",
+	"mpeg": "video/mpeg",
+	"mpg":  "video/mpeg",
+	"3gp":  "video/3gpp",
+	"ts":   "video/mp2t",
+	// Fonts
+	"woff":  "font/woff",
+	"woff2": "font/woff2",
+	"ttf":   "font/ttf",
+	"otf":   "font/otf",
+	"eot":   "application/vnd.ms-fontobject",
+	// Archives
+	"zip": "application/zip",
+	"gz":  "application/gzip",
+	"tgz": "application/gzip",
+	"bz2": "application/x-bzip2",
+	"xz":  "application/x-xz",
+	"7z":  "application/x-7z-compressed",
+	"rar": "application/vnd.rar",
+	"tar": "application/x-tar",
+	"zst": "application/zstd",
+	"lz4": "application/x-lz4",
+	"jar": "application/java-archive",
+	"apk": "application/vnd.android.package-archive",
+	"deb": "application/vnd.debian.binary-package",
+	"rpm": "application/x-rpm",
+	"iso": "application/x-iso9660-image",
+	// Binaries
+	"exe":    "application/vnd.microsoft.portable-executable",
+	"dll":    "application/vnd.microsoft.portable-executable",
+	"so":     "application/x-sharedlib",
+	"wasm":   "application/wasm",
+	"class":  "application/java-vm",
+	"bin":    DefaultMimeType,
+	"sqlite": "application/vnd.sqlite3",
+	"db":     "application/vnd.sqlite3",
+	// Certificates
+	"pem": "application/x-pem-file",
+	"crt": "application/x-x509-ca-cert",
+	"cer": "application/pkix-cert",
+	"der": "application/x-x509-ca-cert",
+	"p12": "application/x-pkcs12",
+	"pfx": "application/x-pkcs12",
+}
+
+// mimeEncodings contains the Content-Encoding of the files stored already compressed, by extension.
+// The MIME type is the one of the decoded content (ex: a svgz is a gzipped svg), so the file must be sent with the
+// Content-Encoding header and must not be compressed again.
+var mimeEncodings = map[string]string{
+	"svgz": "gzip",
+}
+
+// mimeMagic is a sequence of byte expected at the given offset of the content
+type mimeMagic struct {
+	offset int
+	magic  string
+}
+
+// mimeSignature recognize a MIME type from the content: all the magic sequences must match
+type mimeSignature struct {
+	mimeType string
+	magics   []mimeMagic
+	// weak is true for the short printable magic numbers (ex: "MZ"), that can be the start of a text file.
+	// The weak signatures are ignored when the content is text.
+	weak bool
+}
+
+func (s *mimeSignature) match(data []byte) bool {
+	for _, m := range s.magics {
+		if len(data) < m.offset+len(m.magic) || string(data[m.offset:m.offset+len(m.magic)]) != m.magic {
+			return false
+		}
+	}
+	return true
+}
+
+// sig return the signature of a single magic sequence
+func sig(mimeType string, offset int, magic string) mimeSignature {
+	return mimeSignature{mimeType: mimeType, magics: []mimeMagic{{offset: offset, magic: magic}}}
+}
+
+// weakSig return the signature of a single weak magic sequence
+func weakSig(mimeType string, offset int, magic string) mimeSignature {
+	s := sig(mimeType, offset, magic)
+	s.weak = true
+	return s
+}
+
+// mimeSignatures are the magic numbers of the known formats, checked in order
+var mimeSignatures = []mimeSignature{
+	sig("image/png", 0, "\x89PNG\r\n\x1a\n"),
+	sig("image/jpeg", 0, "\xff\xd8\xff"),
+	sig("image/gif", 0, "GIF87a"),
+	sig("image/gif", 0, "GIF89a"),
+	{mimeType: "image/webp", magics: []mimeMagic{{0, "RIFF"}, {8, "WEBP"}}},
+	{mimeType: "audio/wav", magics: []mimeMagic{{0, "RIFF"}, {8, "WAVE"}}},
+	{mimeType: "video/x-msvideo", magics: []mimeMagic{{0, "RIFF"}, {8, "AVI "}}},
+	{mimeType: "image/bmp", magics: []mimeMagic{{0, "BM"}, {6, "\x00\x00\x00\x00"}}},
+	sig("image/x-icon", 0, "\x00\x00\x01\x00"),
+	sig("image/tiff", 0, "II*\x00"),
+	sig("image/tiff", 0, "MM\x00*"),
+	weakSig("image/vnd.adobe.photoshop", 0, "8BPS"),
+	sig("image/avif", 4, "ftypavif"),
+	sig("image/heic", 4, "ftypheic"),
+	sig("video/quicktime", 4, "ftypqt  "),
+	sig("video/mp4", 4, "ftyp"),
+	sig("video/webm", 0, "\x1a\x45\xdf\xa3"),
+	weakSig("audio/ogg", 0, "OggS"),
+	weakSig("audio/flac", 0, "fLaC"),
+	weakSig("audio/mpeg", 0, "ID3"),
+	weakSig("audio/midi", 0, "MThd"),
+	sig("application/pdf", 0, "%PDF-"),
+	sig("application/postscript", 0, "%!PS"),
+	sig("application/rtf", 0, "{\\rtf"),
+	sig("application/zip", 0, "PK\x03\x04"),
+	sig("application/zip", 0, "PK\x05\x06"),
+	sig("application/gzip", 0, "\x1f\x8b"),
+	weakSig("application/x-bzip2", 0, "BZh"),
+	sig("application/x-xz", 0, "\xfd7zXZ\x00"),
+	sig("application/x-7z-compressed", 0, "7z\xbc\xaf\x27\x1c"),
+	sig("application/vnd.rar", 0, "Rar!\x1a\x07"),
+	sig("application/zstd", 0, "\x28\xb5\x2f\xfd"),
+	sig("application/x-lz4", 0, "\x04\x22\x4d\x18"),
+	sig("application/x-tar", 257, "ustar"),
+	sig("application/x-ole-storage", 0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"),
+	sig("application/x-executable", 0, "\x7fELF"),
+	weakSig("application/vnd.microsoft.portable-executable", 0, "MZ"),
+	sig("application/wasm", 0, "\x00asm"),
+	sig("application/vnd.sqlite3", 0, "SQLite format 3\x00"),
+	weakSig("font/woff", 0, "wOFF"),
+	weakSig("font/woff2", 0, "wOF2"),
+	weakSig("font/otf", 0, "OTTO"),
+	sig("font/ttf", 0, "\x00\x01\x00\x00\x00"),
+}
+
+// mimeContainers are the types recognized from the content that can contain more specific formats (ex: a docx is a zip).
+// For these types the extension of the file, if known, is more precise.
+var mimeContainers = []string{
+	"application/zip", "application/x-ole-storage", "application/octet-stream", "text/plain", "text/xml",
+	"video/mp4", "video/webm", "audio/ogg", "application/gzip",
+}
+
+// mimeMu protect the tables of the MIME types, that can be extended with RegisterMimeType and RegisterMimeSignature
+var mimeMu sync.RWMutex
+
+// RegisterMimeType add (or replace) the MIME type of the given extension, with or without the dot
+func RegisterMimeType(extension, mimeType string) {
+	mimeMu.Lock()
+	defer mimeMu.Unlock()
+	mimeTypes[strings.ToLower(strings.TrimPrefix(extension, "."))] = mimeType
+}
+
+// RegisterMimeSignature add a magic number for recognize the given MIME type from the content.
+// The custom signatures are checked before the builtin ones.
+func RegisterMimeSignature(mimeType string, offset int, magic []byte) {
+	mimeMu.Lock()
+	defer mimeMu.Unlock()
+	mimeSignatures = append([]mimeSignature{sig(mimeType, offset, string(magic))}, mimeSignatures...)
+}
+
+// fileExtension return the lowercase extension of the file, without the dot. Both '/' and '\' are path separators.
+func fileExtension(filename string) string {
+	filename = baseFilename(filename)
+	i := strings.LastIndexByte(filename, '.')
+	if i <= 0 { // No extension or hidden file without extension
+		return ""
+	}
+	return strings.ToLower(filename[i+1:])
+}
+
+// baseFilename return the last element of the path, both '/' and '\' are path separators
+func baseFilename(filename string) string {
+	if i := strings.LastIndexAny(filename, `/\`); i != -1 {
+		return filename[i+1:]
+	}
+	return filename
+}
+
+// MimeTypeByExtension return the MIME type of the extension of the given file name
+func MimeTypeByExtension(filename string) (string, bool) {
+	extension := fileExtension(filename)
+	if extension == "" {
+		return "", false
+	}
+	mimeMu.RLock()
+	defer mimeMu.RUnlock()
+	mimeType, ok := mimeTypes[extension]
+	return mimeType, ok
+}
+
+// ContentEncodingByExtension return the Content-Encoding of the files with the given extension stored already compressed
+// (ex: "gzip" for a .svgz), an empty string for the others
+func ContentEncodingByExtension(filename string) string {
+	return mimeEncodings[fileExtension(filename)]
+}
+
+// SniffMimeType return the MIME type recognized from the content (only the first 512 byte are used).
+// The text is recognized as "text/plain; charset=utf-8", the unknown content as DefaultMimeType.
+func SniffMimeType(data []byte) string {
+	if len(data) > mimeSniffLen {
+		data = data[:mimeSniffLen]
+	}
+	text := isText(data)
+	mimeMu.RLock()
+	for i := range mimeSignatures {
+		if mimeSignatures[i].weak && text {
+			continue
+		}
+		if mimeSignatures[i].match(data) {
+			mimeMu.RUnlock()
+			return mimeSignatures[i].mimeType
+		}
+	}
+	mimeMu.RUnlock()
+	// http.DetectContentType recognize the HTML, the XML and the text with the BOM
+	mimeType := http.DetectContentType(data)
+	if text && !strings.HasPrefix(mimeType, "text/") {
+		// Avoid the weak signatures of DetectContentType (ex: "BM" for the bitmap) on plain text
+		return "text/plain; charset=utf-8"
+	}
+	return mimeType
+}
+
+// isText return true if the data are valid UTF-8 without control characters (except the spaces).
+// The last rune can be truncated by the sniff limit.
+func isText(data []byte) bool {
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			return len(data) < utf8.UTFMax && !utf8.FullRune(data)
+		}
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' || r == 0x7f {
+			return false
+		}
+		data = data[size:]
+	}
+	return true
+}
+
+// isMimeContainer return true if the type can contain more specific formats
+func isMimeContainer(mimeType string) bool {
+	if i := strings.IndexByte(mimeType, ';'); i != -1 {
+		mimeType = mimeType[:i]
+	}
+	for _, container := range mimeContainers {
+		if mimeType == container {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectMimeType return the MIME type of a file, using both the name and the content (can be nil).
+// The type recognized from the content wins, unless it is a generic container (ex: zip for a docx) and the extension is known.
+func DetectMimeType(filename string, data []byte) string {
+	byExtension, known := MimeTypeByExtension(filename)
+	known = known && byExtension != DefaultMimeType
+	if len(data) == 0 {
+		if known {
+			return byExtension
+		}
+		return DefaultMimeType
+	}
+	sniffed := SniffMimeType(data)
+	if known && isMimeContainer(sniffed) {
+		if strings.HasPrefix(sniffed, "text/") && strings.HasPrefix(byExtension, "text/") && !strings.Contains(byExtension, ";") {
+			// Keep the charset found in the content
+			return byExtension + sniffed[strings.IndexByte(sniffed+";", ';'):]
+		}
+		return byExtension
+	}
+	return sniffed
+}
+
+// DetectFileMimeType return the MIME type of the given file, reading the first 512 byte of the content
+func DetectFileMimeType(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", newFileError("open", filename, err)
+	}
+	defer file.Close()
+	data := make([]byte, mimeSniffLen)
+	n, err := io.ReadFull(file, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", newFileError("read", filename, err)
+	}
+	return DetectMimeType(filename, data[:n]), nil
+}
+
+// ContentDisposition return the value of the Content-Disposition header for the given file, as defined by the RFC 6266.
+// disposition is DispositionInline or DispositionAttachment, only the last element of the path is used as file name.
+// The "filename" parameter contains an ASCII version of the name for the old clients, the "filename*" parameter
+// (RFC 5987) the UTF-8 name, added only when the name contains characters that can not be sent as they are.
+func ContentDisposition(disposition, filename string) string {
+	if disposition != DispositionAttachment {
+		disposition = DispositionInline
+	}
+	filename = baseFilename(filename)
+	if filename == "" {
+		return disposition
+	}
+	var fallback bytes.Buffer
+	plain := true
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || r == '%':
+			fallback.WriteByte('_')
+			plain = false
+		case r < 0x20 || r == 0x7f:
+			plain = false
+		case r >= utf8.RuneSelf:
+			fallback.WriteByte('_')
+			plain = false
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	header := disposition + `; filename="` + fallback.String() + `"`
+	if !plain {
+		header += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return header
+}
+
+// encodeRFC5987 percent-encode the byte that are not an attr-char of the RFC 5987
+func encodeRFC5987(value string) string {
+	const hex = "0123456789ABCDEF"
+	var buf bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) != -1 {
+			buf.WriteByte(c)
+		} else {
+			buf.WriteByte('%')
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0x0f])
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectMimeTypeWeakSignatures(t *testing.T) {
+	cases := []struct {
+		filename string
+		data     string
+		expected string
+	}{
+		// The text starting with a weak magic number keep the type of the extension
+		{"notes.txt", "MZ is the start of this note\n", "text/plain"},
+		{"data.csv", "ID3,title,artist\n1,a,b\n", "text/csv"},
+		{"readme", "BZh is not a bzip2\n", "text/plain"},
+		// The binary content is still recognized
+		{"program.txt", "MZ\x90\x00\x03\x00\x00\x00", "application/vnd.microsoft.portable-executable"},
+		{"song.txt", "ID3\x03\x00\x00\x00\x00\x00\x00", "audio/mpeg"},
+		{"archive", "BZh91AY&SY\x00\x01\xff", "application/x-bzip2"},
+	}
+	for _, c := range cases {
+		if mimeType := DetectMimeType(c.filename, []byte(c.data)); !strings.HasPrefix(mimeType, c.expected) {
+			t.Errorf("%s: DetectMimeType = %s, expected %s", c.filename, mimeType, c.expected)
+		}
+	}
+}
+
+func TestContentEncodingByExtension(t *testing.T) {
+	if encoding := ContentEncodingByExtension("images/logo.SVGZ"); encoding != "gzip" {
+		t.Errorf("svgz: Content-Encoding = %q, expected gzip", encoding)
+	}
+	if encoding := ContentEncodingByExtension("images/logo.svg"); encoding != "" {
+		t.Errorf("svg: unexpected Content-Encoding %q", encoding)
+	}
+	if mimeType, _ := MimeTypeByExtension("logo.svgz"); mimeType != "image/svg+xml" {
+		t.Errorf("svgz: MIME type = %s", mimeType)
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrMappedFileClosed is returned when a MappedFile is used after Close
+var ErrMappedFileClosed = errors.New("mapped file already closed")
+
+// MappedFile is a read only view of a file mapped in memory, used for scan big files without copy them in the Go heap.
+// The methods are safe for concurrent use; Close wait the running methods and View calls before release the mapping.
+// The view is searched with the helpers of search.go inside View, ex:
+//
+//	m.View(func(data []byte) error { found = CompareDataBytes(data, "target"); return nil })
+//
+// WARNING: the file must not be truncated while it is mapped: the access to the pages after the new end of the file
+// (by the methods or by the slice returned by Bytes) raise a SIGBUS that crash the program.
+// Replace the file with a rename instead of rewrite it in place.
+type MappedFile struct {
+	mu     sync.RWMutex
+	data   []byte
+	closed bool
+	// unmap release the memory, nil when the data are not mapped (empty file or unsupported platform)
+	unmap func([]byte) error
+}
+
+// OpenMappedFile map the given file in memory
+func OpenMappedFile(filename string) (*MappedFile, error) {
+	data, unmap, err := mapFile(filename)
+	if err != nil {
+		return nil, newFileError("mmap", filename, err)
+	}
+	return &MappedFile{data: data, unmap: unmap}, nil
+}
+
+// View call fn with the view of the whole file, Close wait the end of fn before release the mapping.
+// The slice must not be modified and must not be retained after fn return. The error of fn is returned.
+func (m *MappedFile) View(fn func(data []byte) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrMappedFileClosed
+	}
+	return fn(m.data)
+}
+
+// Bytes return the view of the whole file.
+// WARNING: nothing protect the slice from Close: the access after (or during) Close read unmapped memory and crash
+// the program. The caller must ensure that Close is not called while the slice is in use, otherwise use View.
+// The slice must not be modified.
+func (m *MappedFile) Bytes() []byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil
+	}
+	return m.data
+}
+
+// Len return the size of the file
+func (m *MappedFile) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data)
+}
+
+// ReadAt implements the io.ReaderAt interface, so the file can be used with TailData and CountLinesRange
+func (m *MappedFile) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return 0, ErrMappedFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("MappedFile.ReadAt: negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Reader return a reader of the whole file, used for load data (ex: a dictionary) without read the file again
+func (m *MappedFile) Reader() *io.SectionReader {
+	return io.NewSectionReader(m, 0, int64(m.Len()))
+}
+
+// Lines call fn for every line of the file (without the new line), until fn return false.
+// The line is a view of the mapped memory, it must not be retained after fn return.
+func (m *MappedFile) Lines(fn func(line []byte) bool) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrMappedFileClosed
+	}
+	data := m.data
+	for len(data) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+		if !fn(line) {
+			break
+		}
+	}
+	return nil
+}
+
+// Index return the offset of the first occurrence of needle in the file, -1 if not present
+func (m *MappedFile) Index(needle []byte) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return -1
+	}
+	return bytes.Index(m.data, needle)
+}
+
+// IndexFold return the offset of the first occurrence of needle in the file ignoring the case, -1 if not present
+func (m *MappedFile) IndexFold(needle []byte) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return -1
+	}
+	return IndexFoldBytes(m.data, needle)
+}
+
+// Contains verify if the given string is present in the file
+func (m *MappedFile) Contains(needle string) bool {
+	return m.Index([]byte(needle)) != -1
+}
+
+// ContainsFold verify if the given string is present in the file ignoring the case
+func (m *MappedFile) ContainsFold(needle string) bool {
+	return m.IndexFold([]byte(needle)) != -1
+}
+
+// Close release the mapping. It is safe to call Close more than once.
+func (m *MappedFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	data := m.data
+	m.data = nil
+	if m.unmap != nil && len(data) > 0 {
+		return m.unmap(data)
+	}
+	return nil
+}
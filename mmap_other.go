@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package utils
+
+import "io/ioutil"
+
+// mapFile read the whole file in memory on the platforms where mmap is not available
+func mapFile(filename string) ([]byte, func([]byte) error, error) {
+	data, err := ioutil.ReadFile(filename)
+	return data, nil, err
+}
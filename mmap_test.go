@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMappedFileView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "dict.txt")
+	if err = ioutil.WriteFile(filename, []byte("first\nSecond line\nthird"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := OpenMappedFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var found bool
+	if err = m.View(func(data []byte) error {
+		found = CompareDataInsensitiveBytes(data, "second LINE")
+		return nil
+	}); err != nil || !found {
+		t.Errorf("View = %v, found = %v", err, found)
+	}
+	var lines []string
+	m.Lines(func(line []byte) bool {
+		lines = append(lines, string(line))
+		return true
+	})
+	if len(lines) != 3 || lines[2] != "third" {
+		t.Errorf("Lines = %q", lines)
+	}
+
+	// Close wait the end of the running View
+	entered, closed := make(chan struct{}), make(chan struct{})
+	go func() {
+		m.View(func(data []byte) error {
+			close(entered)
+			time.Sleep(50 * time.Millisecond)
+			// The mapping is still valid
+			if string(data[:5]) != "first" {
+				t.Error("unexpected content during Close")
+			}
+			select {
+			case <-closed:
+				t.Error("Close returned before the end of View")
+			default:
+			}
+			return nil
+		})
+	}()
+	<-entered
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(closed)
+	if err = m.View(func([]byte) error { return nil }); err != ErrMappedFileClosed {
+		t.Errorf("View after Close = %v, expected ErrMappedFileClosed", err)
+	}
+	if m.Bytes() != nil || m.Index([]byte("first")) != -1 {
+		t.Error("the view must be empty after Close")
+	}
+}
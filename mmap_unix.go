@@ -0,0 +1,36 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mapFile map the whole file in memory in read only mode
+func mapFile(filename string) ([]byte, func([]byte) error, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The mapping remain valid after the close of the descriptor
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 { // Empty file can not be mapped
+		return nil, nil, nil
+	}
+	if size != int64(int(size)) {
+		return nil, nil, errors.New("file too large to be mapped")
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, syscall.Munmap, nil
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathErrorReason is the reason of the rejection of a path
+type PathErrorReason int
+
+// Reasons of the rejection of a path
+const (
+	// PathEmpty is an empty path
+	PathEmpty PathErrorReason = iota + 1
+	// PathNulByte is a path that contains a NUL byte, that truncate the path in the C functions
+	PathNulByte
+	// PathEncoded is a path that contains a percent-encoded dot, slash, backslash or NUL (ex: %2e%2e), tipically a double encoding attack
+	PathEncoded
+	// PathBackslash is a path that contains a backslash, a path separator on Windows
+	PathBackslash
+	// PathAbsolute is an absolute path (ex: /etc/passwd or C:\)
+	PathAbsolute
+	// PathTraversal is a path that escape the root using ".."
+	PathTraversal
+	// PathHidden is a path that contains an hidden element (starting with a dot)
+	PathHidden
+	// PathSymlinkEscape is a path that escape the root through a symlink
+	PathSymlinkEscape
+	// PathNotFound is a path that does not exist
+	PathNotFound
+	// PathSystem is a path in a directory of the system (ex: /etc), rejected by ValidateInjection
+	PathSystem
+)
+
+var pathErrorReasons = map[PathErrorReason]string{
+	PathEmpty:         "empty path",
+	PathNulByte:       "NUL byte in path",
+	PathEncoded:       "percent-encoded sequence in path",
+	PathBackslash:     "backslash in path",
+	PathAbsolute:      "absolute path",
+	PathTraversal:     "path traversal outside the root",
+	PathHidden:        "hidden file in path",
+	PathSymlinkEscape: "symlink outside the root",
+	PathNotFound:      "path not found",
+	PathSystem:        "system directory in path",
+}
+
+func (r PathErrorReason) String() string {
+	if description, ok := pathErrorReasons[r]; ok {
+		return description
+	}
+	return "invalid path"
+}
+
+// PathValidationError is returned when a path is rejected, Reason contains the cause
+type PathValidationError struct {
+	Path   string
+	Reason PathErrorReason
+	// Err is the underlying error, ex: os.ErrNotExist for PathNotFound
+	Err error
+}
+
+func (e *PathValidationError) Error() string {
+	if e.Err != nil {
+		return e.Reason.String() + ": " + e.Path + ": " + e.Err.Error()
+	}
+	return e.Reason.String() + ": " + e.Path
+}
+
+func (e *PathValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PathErrorReasonOf return the reason of the rejection of the path, 0 if err is not a PathValidationError
+func PathErrorReasonOf(err error) PathErrorReason {
+	var pathErr *PathValidationError
+	if errors.As(err, &pathErr) {
+		return pathErr.Reason
+	}
+	return 0
+}
+
+// encodedPathSequences are the percent-encoded characters that must not be present in a decoded path
+var encodedPathSequences = []string{"%2e", "%2f", "%5c", "%00", "%25"}
+
+// checkPathCharacters verify that the path does not contain NUL bytes, encoded sequences and backslashes
+func checkPathCharacters(p string) PathErrorReason {
+	if strings.IndexByte(p, 0) != -1 {
+		return PathNulByte
+	}
+	lower := strings.ToLower(p)
+	for _, sequence := range encodedPathSequences {
+		if strings.Contains(lower, sequence) {
+			return PathEncoded
+		}
+	}
+	if strings.IndexByte(p, '\\') != -1 {
+		return PathBackslash
+	}
+	return 0
+}
+
+// isAbsolutePath return true for the absolute paths, both Unix and Windows (drive letter)
+func isAbsolutePath(p string) bool {
+	return strings.HasPrefix(p, "/") || filepath.IsAbs(p) || (len(p) >= 2 && p[1] == ':' && (p[0]|0x20 >= 'a' && p[0]|0x20 <= 'z'))
+}
+
+// CheckRelativePath verify that the given path is relative and does not escape its root, without access the filesystem.
+// The ".." elements are allowed while they remain inside the root (ex: "a/../b"), the names that contain two dots (ex: "v1..2") are allowed.
+func CheckRelativePath(p string) error {
+	if p == "" {
+		return &PathValidationError{Path: p, Reason: PathEmpty}
+	}
+	if reason := checkPathCharacters(p); reason != 0 {
+		return &PathValidationError{Path: p, Reason: reason}
+	}
+	if isAbsolutePath(p) {
+		return &PathValidationError{Path: p, Reason: PathAbsolute}
+	}
+	if cleaned := path.Clean(p); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return &PathValidationError{Path: p, Reason: PathTraversal}
+	}
+	return nil
+}
+
+// PathValidator resolve the paths supplied by the users inside an allowed root directory.
+// The symlinks are followed, and the paths that escape the root (also through a symlink) are rejected.
+type PathValidator struct {
+	root string
+	// AllowHidden allow the elements of the path that start with a dot (ex: .git)
+	AllowHidden bool
+	// AllowMissing allow the paths that do not exist (ex: a file to create): the existing part of the path is resolved
+	AllowMissing bool
+}
+
+// NewPathValidator return a validator for the given root directory
+func NewPathValidator(root string) (*PathValidator, error) {
+	absolute, err := filepath.Abs(root)
+	if err != nil {
+		return nil, newFileError("abs", root, err)
+	}
+	// The root is resolved in order to compare it with the resolved path of the files
+	if absolute, err = filepath.EvalSymlinks(absolute); err != nil {
+		return nil, newFileError("stat", root, err)
+	}
+	if isDir, err := IsDirE(absolute); err != nil {
+		return nil, err
+	} else if !isDir {
+		return nil, newFileError("stat", root, errors.New("not a directory"))
+	}
+	return &PathValidator{root: absolute}, nil
+}
+
+// Root return the absolute path of the root, with the symlinks resolved
+func (v *PathValidator) Root() string {
+	return v.root
+}
+
+// contains return true if the given absolute path is the root or is inside it
+func (v *PathValidator) contains(p string) bool {
+	return p == v.root || strings.HasPrefix(p, v.root+string(filepath.Separator))
+}
+
+// Resolve return the absolute path (with the symlinks resolved) of the given relative path inside the root.
+// A *PathValidationError is returned if the path is rejected, it wrap os.ErrNotExist when the path does not exist.
+func (v *PathValidator) Resolve(userPath string) (string, error) {
+	if err := CheckRelativePath(userPath); err != nil {
+		return "", err
+	}
+	cleaned := path.Clean(userPath)
+	if !v.AllowHidden {
+		for _, element := range strings.Split(cleaned, "/") {
+			if strings.HasPrefix(element, ".") && element != "." {
+				return "", &PathValidationError{Path: userPath, Reason: PathHidden}
+			}
+		}
+	}
+	filename := filepath.Join(v.root, filepath.FromSlash(cleaned))
+	resolved, err := filepath.EvalSymlinks(filename)
+	if err != nil && v.AllowMissing && errors.Is(err, os.ErrNotExist) {
+		resolved, err = v.resolveMissing(filename)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", &PathValidationError{Path: userPath, Reason: PathNotFound, Err: err}
+		}
+		return "", err
+	}
+	if !v.contains(resolved) {
+		return "", &PathValidationError{Path: userPath, Reason: PathSymlinkEscape}
+	}
+	return resolved, nil
+}
+
+// resolveMissing resolve the longest existing parent of the path, then append the missing elements
+func (v *PathValidator) resolveMissing(filename string) (string, error) {
+	var missing []string
+	for current := filename; ; {
+		parent, name := filepath.Dir(current), filepath.Base(current)
+		missing = append([]string{name}, missing...)
+		if parent == current {
+			return "", os.ErrNotExist
+		}
+		resolved, err := filepath.EvalSymlinks(parent)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		current = parent
+	}
+}
+
+// Validate return an error if the given path can not be resolved inside the root
+func (v *PathValidator) Validate(userPath string) error {
+	_, err := v.Resolve(userPath)
+	return err
+}
+
+// PayloadValidationError is returned when a payload does not respect a rule of the PayloadRules
+type PayloadValidationError struct {
+	Payload string
+	// Rule is the name of the rule violated, ex: "max-length" or "deny"
+	Rule string
+	// Detail contains the value that caused the rejection, ex: the denied substring
+	Detail string
+}
+
+func (e *PayloadValidationError) Error() string {
+	if e.Detail != "" {
+		return "payload rejected by rule " + e.Rule + " [" + e.Detail + "]"
+	}
+	return "payload rejected by rule " + e.Rule
+}
+
+// PayloadRules is a set of rules for validate the strings supplied by the users. The empty rules are ignored.
+type PayloadRules struct {
+	MinLength int
+	MaxLength int
+	// Allow must match the whole payload, ex: ^[a-zA-Z0-9_-]+$
+	Allow *regexp.Regexp
+	// MustContain contains the strings of which at least one must be present
+	MustContain []string
+	// Deny contains the substrings that are not allowed
+	Deny []string
+	// DenyPatterns contains the patterns that must not match any part of the payload
+	DenyPatterns []*regexp.Regexp
+	// SafePath verify the payload with CheckRelativePath
+	SafePath bool
+}
+
+// FilenamePayloadRules allow a single file name, without path separators and hidden files
+var FilenamePayloadRules = &PayloadRules{
+	MinLength: 1,
+	MaxLength: 255,
+	Allow:     regexp.MustCompile(`^[\p{L}\p{N}_\-][\p{L}\p{N}_\-. ]*$`),
+}
+
+// IdentifierPayloadRules allow the identifiers made of letters, numbers, underscore and dash
+var IdentifierPayloadRules = &PayloadRules{
+	MinLength: 1,
+	MaxLength: 128,
+	Allow:     regexp.MustCompile(`^[A-Za-z0-9_\-]+$`),
+}
+
+// Validate return a *PayloadValidationError if the payload does not respect the rules
+func (r *PayloadRules) Validate(payload string) error {
+	if len(payload) < r.MinLength {
+		return &PayloadValidationError{Payload: payload, Rule: "min-length"}
+	}
+	if r.MaxLength > 0 && len(payload) > r.MaxLength {
+		return &PayloadValidationError{Payload: payload, Rule: "max-length"}
+	}
+	if r.Allow != nil && !r.Allow.MatchString(payload) {
+		return &PayloadValidationError{Payload: payload, Rule: "allow", Detail: r.Allow.String()}
+	}
+	if len(r.MustContain) > 0 {
+		found := false
+		for _, word := range r.MustContain {
+			if strings.Contains(payload, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &PayloadValidationError{Payload: payload, Rule: "must-contain"}
+		}
+	}
+	for _, word := range r.Deny {
+		if strings.Contains(payload, word) {
+			return &PayloadValidationError{Payload: payload, Rule: "deny", Detail: word}
+		}
+	}
+	for _, pattern := range r.DenyPatterns {
+		if pattern.MatchString(payload) {
+			return &PayloadValidationError{Payload: payload, Rule: "deny-pattern", Detail: pattern.String()}
+		}
+	}
+	if r.SafePath {
+		if err := CheckRelativePath(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// systemDirectories are the directories rejected by ValidateInjection
+var systemDirectories = []string{"/etc", "/bin", "/sbin", "/usr", "/var", "/proc", "/sys", "/dev", "/boot", "/root"}
+
+// checkInjectionPath verify a path (relative or absolute) for ValidateInjection: the ".." elements and the system directories are rejected
+func checkInjectionPath(payload string) error {
+	if reason := checkPathCharacters(payload); reason != 0 {
+		return &PathValidationError{Path: payload, Reason: reason}
+	}
+	for _, element := range strings.Split(payload, "/") {
+		if element == ".." {
+			return &PathValidationError{Path: payload, Reason: PathTraversal}
+		}
+	}
+	cleaned := path.Clean(payload)
+	for _, dir := range systemDirectories {
+		if cleaned == dir || strings.HasPrefix(cleaned, dir+"/") {
+			return &PathValidationError{Path: payload, Reason: PathSystem}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// clockTicks is the USER_HZ of the CPU times in /proc/[pid]/stat, 100 on all the supported architectures
+const clockTicks = 100
+
+// ErrProcUnsupported is returned when the process and host stats are not available on the platform
+var ErrProcUnsupported = errors.New("process stats not supported on this platform")
+
+// ProcessStats contains the resources used by the process, read from /proc/self
+type ProcessStats struct {
+	PID int
+	// RSS is the resident memory in bytes
+	RSS uint64
+	// VirtualMemory is the size of the virtual memory in bytes
+	VirtualMemory uint64
+	Threads       int
+	OpenFDs       int
+	// MaxFDs is the soft limit of the open file descriptors
+	MaxFDs    uint64
+	CPUUser   time.Duration
+	CPUSystem time.Duration
+	// ReadBytes and WriteBytes are the bytes read from and written to the storage, 0 if /proc/self/io is not readable
+	ReadBytes  uint64
+	WriteBytes uint64
+	// ReadChars and WriteChars are the bytes read and written by the syscalls (also from the pipes and the sockets)
+	ReadChars  uint64
+	WriteChars uint64
+}
+
+// DiskUsage is the usage of the filesystem that contains a path
+type DiskUsage struct {
+	Path  string
+	Total uint64
+	Free  uint64
+	// Available is the space available for the unprivileged users
+	Available   uint64
+	Used        uint64
+	UsedPercent float64
+}
+
+// HostStats contains the load and the memory of the host, read from /proc, and the usage of the disks
+type HostStats struct {
+	Load1  float64
+	Load5  float64
+	Load15 float64
+	// The memory is in bytes
+	MemTotal     uint64
+	MemFree      uint64
+	MemAvailable uint64
+	Buffers      uint64
+	Cached       uint64
+	SwapTotal    uint64
+	SwapFree     uint64
+	Disks        []DiskUsage
+}
+
+// ProcFS read the stats of the process and of the host from a proc filesystem
+type ProcFS struct {
+	// Root is the mount point of the proc filesystem, ex: "/proc" or a fake directory with the same layout
+	Root string
+	// DiskPaths are the paths of the disks included in the HostStats of the snapshots
+	DiskPaths []string
+}
+
+// DefaultProcFS is used by ReadProcessStats, ReadHostStats and the runtime snapshots. The Root is empty on the
+// platforms without /proc, where the stats are not available.
+var DefaultProcFS = &ProcFS{Root: defaultProcRoot, DiskPaths: []string{"/"}}
+
+// NewProcFS return a ProcFS for the given root, the default root (/proc on Linux) if empty
+func NewProcFS(root string) *ProcFS {
+	if root == "" {
+		root = defaultProcRoot
+	}
+	return &ProcFS{Root: root, DiskPaths: []string{"/"}}
+}
+
+// path return the path of the given file of the proc filesystem
+func (p *ProcFS) path(elem ...string) string {
+	return filepath.Join(append([]string{p.Root}, elem...)...)
+}
+
+// readFile read a file of the proc filesystem
+func (p *ProcFS) readFile(elem ...string) ([]byte, error) {
+	filename := p.path(elem...)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	return data, nil
+}
+
+// parseKeyValues parse the lines "key: value [unit]" (ex: /proc/self/status and /proc/meminfo) calling the function
+// for every line. The values in kB are converted in bytes.
+func parseKeyValues(data []byte, fn func(key string, value uint64)) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ':')
+		if i == -1 {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+		fn(line[:i], value)
+	}
+}
+
+// ProcessStats return the resources used by the current process
+func (p *ProcFS) ProcessStats() (*ProcessStats, error) {
+	if p.Root == "" {
+		return nil, ErrProcUnsupported
+	}
+	stats := &ProcessStats{PID: os.Getpid()}
+
+	status, err := p.readFile("self", "status")
+	if err != nil {
+		return nil, err
+	}
+	parseKeyValues(status, func(key string, value uint64) {
+		switch key {
+		case "VmRSS":
+			stats.RSS = value
+		case "VmSize":
+			stats.VirtualMemory = value
+		case "Threads":
+			stats.Threads = int(value)
+		case "Pid":
+			stats.PID = int(value)
+		}
+	})
+
+	stat, err := p.readFile("self", "stat")
+	if err != nil {
+		return nil, err
+	}
+	// The name of the command (2nd field) can contain spaces and parenthesis, the fields start after the last ')'
+	i := bytes.LastIndexByte(stat, ')')
+	if i == -1 {
+		return nil, newFileError("parse", p.path("self", "stat"), errors.New("invalid format"))
+	}
+	// fields[0] is the 3rd field (state), utime and stime are the 14th and 15th
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 13 {
+		return nil, newFileError("parse", p.path("self", "stat"), errors.New("invalid format"))
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	stats.CPUUser = time.Duration(utime) * time.Second / clockTicks
+	stats.CPUSystem = time.Duration(stime) * time.Second / clockTicks
+
+	if stats.OpenFDs, err = p.countFDs(); err != nil {
+		return nil, err
+	}
+
+	if limits, err := p.readFile("self", "limits"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(limits))
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "Max open files") {
+				if fields := strings.Fields(line[len("Max open files"):]); len(fields) > 0 {
+					stats.MaxFDs, _ = strconv.ParseUint(fields[0], 10, 64)
+				}
+			}
+		}
+	}
+
+	// /proc/self/io is not readable in some containers, the I/O stats are optional
+	if ioStats, err := p.readFile("self", "io"); err == nil {
+		parseKeyValues(ioStats, func(key string, value uint64) {
+			switch key {
+			case "read_bytes":
+				stats.ReadBytes = value
+			case "write_bytes":
+				stats.WriteBytes = value
+			case "rchar":
+				stats.ReadChars = value
+			case "wchar":
+				stats.WriteChars = value
+			}
+		})
+	}
+	return stats, nil
+}
+
+// countFDs return the number of the open file descriptors, reading only the names of /proc/self/fd (the entries are
+// symbolic links, a stat for every descriptor is not needed). The descriptor used for read the directory is excluded.
+func (p *ProcFS) countFDs() (int, error) {
+	dirname := p.path("self", "fd")
+	dir, err := os.Open(dirname)
+	if err != nil {
+		return 0, newFileError("open", dirname, err)
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, newFileError("readdir", dirname, err)
+	}
+	self := strconv.FormatUint(uint64(dir.Fd()), 10)
+	for _, name := range names {
+		if name == self {
+			return len(names) - 1, nil
+		}
+	}
+	return len(names), nil
+}
+
+// HostStats return the load and the memory of the host, and the usage of the disks that contain the given paths.
+// The disks that can not be read (ex: a path not mounted) are logged and skipped, they are not included in Disks.
+func (p *ProcFS) HostStats(diskPaths ...string) (*HostStats, error) {
+	if p.Root == "" {
+		return nil, ErrProcUnsupported
+	}
+	stats := &HostStats{}
+
+	loadavg, err := p.readFile("loadavg")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(loadavg))
+	if len(fields) < 3 {
+		return nil, newFileError("parse", p.path("loadavg"), errors.New("invalid format"))
+	}
+	stats.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	stats.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	stats.Load15, _ = strconv.ParseFloat(fields[2], 64)
+
+	meminfo, err := p.readFile("meminfo")
+	if err != nil {
+		return nil, err
+	}
+	parseKeyValues(meminfo, func(key string, value uint64) {
+		switch key {
+		case "MemTotal":
+			stats.MemTotal = value
+		case "MemFree":
+			stats.MemFree = value
+		case "MemAvailable":
+			stats.MemAvailable = value
+		case "Buffers":
+			stats.Buffers = value
+		case "Cached":
+			stats.Cached = value
+		case "SwapTotal":
+			stats.SwapTotal = value
+		case "SwapFree":
+			stats.SwapFree = value
+		}
+	})
+
+	for _, diskPath := range diskPaths {
+		usage, err := ReadDiskUsage(diskPath)
+		if err != nil {
+			log.Warn("HostStats | Skipping disk [", diskPath, "] | ERR: ", err)
+			continue
+		}
+		stats.Disks = append(stats.Disks, *usage)
+	}
+	return stats, nil
+}
+
+// ReadDiskUsage return the usage of the filesystem that contains the given path
+func ReadDiskUsage(path string) (*DiskUsage, error) {
+	usage, err := statDisk(path)
+	if err != nil {
+		return nil, err
+	}
+	usage.Path = path
+	usage.Used = usage.Total - usage.Free
+	// As df, the percentage is computed on the space available for the users
+	if total := usage.Used + usage.Available; total > 0 {
+		usage.UsedPercent = float64(usage.Used) / float64(total) * 100
+	}
+	return usage, nil
+}
+
+// ReadProcessStats return the resources used by the current process, using the DefaultProcFS
+func ReadProcessStats() (*ProcessStats, error) {
+	return DefaultProcFS.ProcessStats()
+}
+
+// ReadHostStats return the stats of the host and of the disks that contain the given paths, using the DefaultProcFS
+func ReadHostStats(diskPaths ...string) (*HostStats, error) {
+	return DefaultProcFS.HostStats(diskPaths...)
+}
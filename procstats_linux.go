@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package utils
+
+import "syscall"
+
+// defaultProcRoot is the mount point of the proc filesystem
+const defaultProcRoot = "/proc"
+
+// statDisk return the size, the free and the available space of the filesystem that contains the path
+func statDisk(path string) (*DiskUsage, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return nil, newFileError("statfs", path, err)
+	}
+	blockSize := uint64(fs.Bsize)
+	return &DiskUsage{
+		Total:     fs.Blocks * blockSize,
+		Free:      fs.Bfree * blockSize,
+		Available: fs.Bavail * blockSize,
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package utils
+
+// defaultProcRoot is empty, the proc filesystem is available only on Linux
+const defaultProcRoot = ""
+
+// statDisk return ErrProcUnsupported, the disk usage is available only on Linux
+func statDisk(path string) (*DiskUsage, error) {
+	return nil, ErrProcUnsupported
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeFakeProc create a fake proc filesystem in a temporary directory, return the root that must be removed by the caller
+func writeFakeProc(t *testing.T) string {
+	root, err := ioutil.TempDir("", "fakeproc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(root, "self", "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err = ioutil.WriteFile(filepath.Join(root, "self", "fd", strconv.Itoa(i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"self/status": "Name:\tweird cmd\nUmask:\t0022\nState:\tS (sleeping)\nPid:\t4242\nVmSize:\t  204800 kB\nVmRSS:\t   10240 kB\nThreads:\t9\n",
+		// The command contains spaces and parenthesis, the fields must be read after the last ')'
+		"self/stat":   "4242 (my (weird) cmd) S 1 4242 4242 0 -1 4194560 1000 0 0 0 350 125 0 0 20 0 9 0 12345 209715200 2560 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0",
+		"self/limits": "Limit                     Soft Limit           Hard Limit           Units     \nMax cpu time              unlimited            unlimited            seconds   \nMax open files            1024                 524288               files     \n",
+		"self/io":     "rchar: 1000\nwchar: 2000\nsyscr: 10\nsyscw: 20\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n",
+		"loadavg":     "0.52 1.25 2.00 3/456 7890\n",
+		"meminfo":     "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\nBuffers:          102400 kB\nCached:          2048000 kB\nSwapCached:            0 kB\nSwapTotal:       4096000 kB\nSwapFree:        4000000 kB\nHugePages_Total:       0\n",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestProcessStats(t *testing.T) {
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	stats, err := NewProcFS(root).ProcessStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ProcessStats{
+		PID:           4242,
+		RSS:           10240 * 1024,
+		VirtualMemory: 204800 * 1024,
+		Threads:       9,
+		OpenFDs:       4,
+		MaxFDs:        1024,
+		CPUUser:       3500 * time.Millisecond,
+		CPUSystem:     1250 * time.Millisecond,
+		ReadBytes:     4096,
+		WriteBytes:    8192,
+		ReadChars:     1000,
+		WriteChars:    2000,
+	}
+	if *stats != expected {
+		t.Errorf("ProcessStats = %+v, expected %+v", *stats, expected)
+	}
+}
+
+func TestProcessStatsWithoutIO(t *testing.T) {
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	ioFile := filepath.Join(root, "self", "io")
+
+	// Missing io file
+	if err := os.Remove(ioFile); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := NewProcFS(root).ProcessStats()
+	if err != nil {
+		t.Fatal("missing io file must not be fatal: ", err)
+	}
+	if stats.ReadBytes != 0 || stats.WriteBytes != 0 || stats.RSS != 10240*1024 {
+		t.Errorf("unexpected stats without io file: %+v", *stats)
+	}
+
+	// Unreadable io file (a directory can not be read also by root)
+	if err = os.Mkdir(ioFile, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = NewProcFS(root).ProcessStats(); err != nil {
+		t.Fatal("unreadable io file must not be fatal: ", err)
+	}
+	if stats.ReadBytes != 0 || stats.CPUUser != 3500*time.Millisecond {
+		t.Errorf("unexpected stats with unreadable io file: %+v", *stats)
+	}
+}
+
+func TestProcessStatsInvalid(t *testing.T) {
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "self", "stat"), []byte("4242 no parenthesis"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewProcFS(root).ProcessStats(); err == nil {
+		t.Error("expected an error for an invalid stat file")
+	}
+	if _, err := (&ProcFS{}).ProcessStats(); err != ErrProcUnsupported {
+		t.Errorf("expected ErrProcUnsupported for an empty root, got %v", err)
+	}
+	if _, err := NewProcFS(filepath.Join(root, "missing")).ProcessStats(); err == nil {
+		t.Error("expected an error for a missing root")
+	}
+}
+
+func TestHostStats(t *testing.T) {
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	stats, err := NewProcFS(root).HostStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := HostStats{
+		Load1:        0.52,
+		Load5:        1.25,
+		Load15:       2,
+		MemTotal:     16384000 * 1024,
+		MemFree:      1024000 * 1024,
+		MemAvailable: 8192000 * 1024,
+		Buffers:      102400 * 1024,
+		Cached:       2048000 * 1024,
+		SwapTotal:    4096000 * 1024,
+		SwapFree:     4000000 * 1024,
+	}
+	if !reflect.DeepEqual(*stats, expected) {
+		t.Errorf("HostStats = %+v, expected %+v", *stats, expected)
+	}
+}
+
+func TestHostStatsInvalidLoadavg(t *testing.T) {
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "loadavg"), []byte("0.52\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewProcFS(root).HostStats(); err == nil {
+		t.Error("expected an error for an invalid loadavg file")
+	}
+}
+
+func TestHostStatsDisk(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("disk usage available only on Linux")
+	}
+	root := writeFakeProc(t)
+	defer os.RemoveAll(root)
+	stats, err := NewProcFS(root).HostStats(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Disks) != 1 {
+		t.Fatalf("expected 1 disk, got %d", len(stats.Disks))
+	}
+	disk := stats.Disks[0]
+	if disk.Path != root || disk.Total == 0 || disk.Free > disk.Total || disk.Available > disk.Free || disk.Used != disk.Total-disk.Free {
+		t.Errorf("invalid disk usage: %+v", disk)
+	}
+	// The missing disk is skipped, the other stats are still returned
+	stats, err = NewProcFS(root).HostStats(filepath.Join(root, "missing"), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Disks) != 1 || stats.Disks[0].Path != root || stats.Load1 != 0.52 {
+		t.Errorf("expected only the valid disk and the load, got %+v", *stats)
+	}
+}
+
+func TestProcessStatsOpenFDs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc available only on Linux")
+	}
+	before, err := ReadProcessStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	after, err := ReadProcessStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The descriptor used for read /proc/self/fd is not counted
+	if after.OpenFDs != before.OpenFDs+1 {
+		t.Errorf("OpenFDs = %d after opening a file, expected %d", after.OpenFDs, before.OpenFDs+1)
+	}
+	if names, err := ioutil.ReadDir("/proc/self/fd"); err == nil && after.OpenFDs != len(names)-1 {
+		t.Errorf("OpenFDs = %d, expected %d", after.OpenFDs, len(names)-1)
+	}
+}
@@ -0,0 +1,480 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// Kinds of the profiles
+const (
+	ProfileCPU       = "cpu"
+	ProfileHeap      = "heap"
+	ProfileAllocs    = "allocs"
+	ProfileGoroutine = "goroutine"
+	ProfileBlock     = "block"
+	ProfileMutex     = "mutex"
+	ProfileTrace     = "trace"
+)
+
+const (
+	// DefaultCPUProfileDuration is the duration of the CPU profiles when not specified
+	DefaultCPUProfileDuration = 30 * time.Second
+	// DefaultTraceDuration is the duration of the traces when not specified
+	DefaultTraceDuration = time.Second
+	// MaxPprofDuration is the maximum duration of the profiles requested to the PprofHandler, the longer are truncated
+	MaxPprofDuration = 5 * time.Minute
+)
+
+// ErrUnknownProfile is returned for an unsupported kind of profile
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// blockProfileRate is the rate set with SetBlockProfileRate, the runtime does not expose the current one
+var blockProfileRate int64
+
+// SetBlockProfileRate is delegated to call runtime.SetBlockProfileRate, recording the rate in order to restore it after
+// the block profiles captured by CaptureProfile. Use it instead of runtime.SetBlockProfileRate.
+// It return the previous rate set with this function.
+func SetBlockProfileRate(rate int) int {
+	if rate < 0 {
+		rate = 0
+	}
+	runtime.SetBlockProfileRate(rate)
+	return int(atomic.SwapInt64(&blockProfileRate, int64(rate)))
+}
+
+// StartCPUProfiler Save the cpu profile information into the given file.
+// It return the function that stop the profiler, or the error if the profiler can not be started (ex: already running).
+func StartCPUProfiler(file *os.File) (func(), error) {
+	// Start the cpu profiler
+	if err := pprof.StartCPUProfile(file); err != nil {
+		log.Error("StartCPUProfiler | Could not start CPU profile | ERR: ", err)
+		return nil, err
+	}
+	return pprof.StopCPUProfile, nil
+}
+
+// waitProfile wait the duration of the profile, return the context error if the context is done before
+func waitProfile(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CaptureProfile write the profile of the given kind in w, in the pprof format (the runtime/trace format for ProfileTrace).
+// The CPU profile and the trace last for the duration (default DefaultCPUProfileDuration and DefaultTraceDuration),
+// and are stopped early when the context is done. If the duration is not 0, the block and mutex profiles are enabled
+// during the duration (and the previous rate is restored after, see SetBlockProfileRate); the others are a snapshot
+// and ignore the duration.
+// debug is the debug parameter of pprof.Profile.WriteTo, 0 for the binary format.
+func CaptureProfile(ctx context.Context, w io.Writer, kind string, duration time.Duration, debug int) error {
+	switch kind {
+	case ProfileCPU:
+		if duration <= 0 {
+			duration = DefaultCPUProfileDuration
+		}
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		err := waitProfile(ctx, duration)
+		pprof.StopCPUProfile()
+		return err
+	case ProfileTrace:
+		if duration <= 0 {
+			duration = DefaultTraceDuration
+		}
+		if err := trace.Start(w); err != nil {
+			return err
+		}
+		err := waitProfile(ctx, duration)
+		trace.Stop()
+		return err
+	case ProfileBlock, ProfileMutex:
+		if duration > 0 {
+			if kind == ProfileBlock {
+				// Restore the rate of the application (set using SetBlockProfileRate)
+				defer SetBlockProfileRate(SetBlockProfileRate(1))
+			} else {
+				defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+			}
+			if err := waitProfile(ctx, duration); err != nil {
+				return err
+			}
+		}
+	case ProfileHeap:
+		// Update the statistics of the heap with the last completed GC
+		runtime.GC()
+	case ProfileAllocs, ProfileGoroutine:
+	default:
+		if pprof.Lookup(kind) == nil {
+			return ErrUnknownProfile
+		}
+	}
+	return pprof.Lookup(kind).WriteTo(w, debug)
+}
+
+// profileExtension return the extension of the files of the given kind
+func profileExtension(kind string) string {
+	if kind == ProfileTrace {
+		return ".trace"
+	}
+	return ".pprof"
+}
+
+// CaptureProfileFile write the profile of the given kind in a file (see CaptureProfile)
+func CaptureProfileFile(ctx context.Context, filename, kind string, duration time.Duration) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return newFileError("create", filename, err)
+	}
+	if err = CaptureProfile(ctx, file, kind, duration, 0); err != nil {
+		file.Close()
+		os.Remove(filename)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return newFileError("close", filename, err)
+	}
+	return nil
+}
+
+// ProfilerOptions contains the parameters of the Profiler
+type ProfilerOptions struct {
+	// Kinds are the profiles captured by Run, ProfileCPU and ProfileHeap if empty
+	Kinds []string
+	// Interval between the captures of Run, 0 disable the continuous profiling
+	Interval time.Duration
+	// CPUDuration is the duration of the CPU profile and of the trace captured by Run, DefaultCPUProfileDuration if 0
+	CPUDuration time.Duration
+	// MaxFiles is the number of files of every kind kept in the directory, the oldest are removed. 0 for keep all the files.
+	MaxFiles int
+	// HeapThreshold is the size in bytes of the heap (HeapAlloc) over which a heap profile is saved, 0 for disable.
+	// A new profile is saved only after the heap is returned under the threshold.
+	HeapThreshold uint64
+	// HeapCheckInterval is the interval between the checks of the heap of Run, 10 seconds if 0
+	HeapCheckInterval time.Duration
+}
+
+// Profiler capture the profiles into a directory, once (Capture) or continuously (Run), and save a heap
+// profile when the heap cross a threshold.
+type Profiler struct {
+	dir  string
+	opts ProfilerOptions
+
+	mu sync.Mutex
+	// overThreshold is true when the heap is over the threshold and the profile is already saved
+	overThreshold bool
+}
+
+// NewProfiler return a Profiler that save the profiles in the given directory, created if not exists. opts can be nil.
+func NewProfiler(dir string, opts *ProfilerOptions) (*Profiler, error) {
+	if opts == nil {
+		opts = &ProfilerOptions{}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, newFileError("mkdir", dir, err)
+	}
+	p := &Profiler{dir: dir, opts: *opts}
+	if len(p.opts.Kinds) == 0 {
+		p.opts.Kinds = []string{ProfileCPU, ProfileHeap}
+	}
+	if p.opts.CPUDuration <= 0 {
+		p.opts.CPUDuration = DefaultCPUProfileDuration
+	}
+	if p.opts.HeapCheckInterval <= 0 {
+		p.opts.HeapCheckInterval = 10 * time.Second
+	}
+	return p, nil
+}
+
+// Capture save the profile of the given kind in a new file of the directory (ex: cpu-20200102T150405.000.pprof), return the file name
+func (p *Profiler) Capture(ctx context.Context, kind string, duration time.Duration) (string, error) {
+	filename := filepath.Join(p.dir, kind+"-"+time.Now().Format("20060102T150405.000")+profileExtension(kind))
+	if err := CaptureProfileFile(ctx, filename, kind, duration); err != nil {
+		log.Error("Profiler | Unable to capture the profile [", kind, "] | ERR: ", err)
+		return "", err
+	}
+	log.Debug("Profiler | Profile [", kind, "] saved in [", filename, "]")
+	p.rotate(kind)
+	return filename, nil
+}
+
+// rotate remove the oldest files of the given kind, keeping MaxFiles files
+func (p *Profiler) rotate(kind string) {
+	if p.opts.MaxFiles <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(p.dir, kind+"-*"+profileExtension(kind)))
+	if err != nil || len(files) <= p.opts.MaxFiles {
+		return
+	}
+	// The timestamp in the name make the lexical order the chronological one
+	sort.Strings(files)
+	for _, file := range files[:len(files)-p.opts.MaxFiles] {
+		if err := os.Remove(file); err != nil {
+			log.Warn("Profiler | Unable to remove the old profile [", file, "] | ERR: ", err)
+		}
+	}
+}
+
+// CheckHeap save a heap profile if the given heap size cross the threshold, return the file name if saved.
+// It can be used as the callback of a MemoryManager with HeapSnapshotCallback.
+func (p *Profiler) CheckHeap(heapAlloc uint64) (string, error) {
+	if p.opts.HeapThreshold == 0 {
+		return "", nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if heapAlloc <= p.opts.HeapThreshold {
+		p.overThreshold = false
+		return "", nil
+	}
+	if p.overThreshold {
+		return "", nil
+	}
+	log.Warn("Profiler | Heap [", bToMb(heapAlloc), "MiB] over the threshold [", bToMb(p.opts.HeapThreshold), "MiB], saving the heap profile")
+	filename, err := p.Capture(context.Background(), ProfileHeap, 0)
+	if err == nil {
+		p.overThreshold = true
+	}
+	return filename, err
+}
+
+// HeapSnapshotCallback return a callback for the MemoryManagerOptions that save a heap profile when the heap cross the threshold
+func (p *Profiler) HeapSnapshotCallback() func(*RuntimeSnapshot, bool) {
+	return func(snapshot *RuntimeSnapshot, _ bool) {
+		p.CheckHeap(snapshot.HeapAlloc)
+	}
+}
+
+// Run capture the profiles every Interval and check the heap every HeapCheckInterval until the context is done.
+// The CPU profile and the trace are captured in a dedicated goroutine, for not delay the other profiles.
+// It return the context error.
+func (p *Profiler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var captures <-chan time.Time
+	if p.opts.Interval > 0 {
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+		captures = ticker.C
+	}
+	var checks <-chan time.Time
+	if p.opts.HeapThreshold > 0 {
+		ticker := time.NewTicker(p.opts.HeapCheckInterval)
+		defer ticker.Stop()
+		checks = ticker.C
+	}
+	// running avoid to start a CPU profile or a trace while the previous one is not completed
+	running := make(map[string]bool)
+	var runningMu sync.Mutex
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-checks:
+			var rtm runtime.MemStats
+			runtime.ReadMemStats(&rtm)
+			p.CheckHeap(rtm.HeapAlloc)
+		case <-captures:
+			for _, kind := range p.opts.Kinds {
+				if kind != ProfileCPU && kind != ProfileTrace {
+					p.Capture(ctx, kind, 0)
+					continue
+				}
+				runningMu.Lock()
+				if running[kind] {
+					runningMu.Unlock()
+					continue
+				}
+				running[kind] = true
+				runningMu.Unlock()
+				wg.Add(1)
+				go func(kind string) {
+					defer wg.Done()
+					duration := p.opts.CPUDuration
+					if kind == ProfileTrace && duration > DefaultTraceDuration*10 {
+						// The traces are big, limit them to few seconds
+						duration = DefaultTraceDuration * 10
+					}
+					p.Capture(ctx, kind, duration)
+					runningMu.Lock()
+					running[kind] = false
+					runningMu.Unlock()
+				}(kind)
+			}
+		}
+	}
+}
+
+// pprofDescriptions are the descriptions of the profiles in the index of the PprofHandler
+var pprofDescriptions = map[string]string{
+	"allocs":       "A sampling of all past memory allocations",
+	"block":        "Stack traces that led to blocking on synchronization primitives",
+	"cmdline":      "The command line invocation of the current program",
+	"goroutine":    "Stack traces of all current goroutines",
+	"heap":         "A sampling of memory allocations of live objects. You can specify the gc GET parameter to run GC before taking the heap sample.",
+	"mutex":        "Stack traces of holders of contended mutexes",
+	"profile":      "CPU profile. You can specify the duration in the seconds GET parameter.",
+	"threadcreate": "Stack traces that led to the creation of new OS threads",
+	"trace":        "A trace of execution of the current program. You can specify the duration in the seconds GET parameter.",
+}
+
+// PprofHandler return a fasthttp handler that serve the same endpoints of net/http/pprof under the given prefix
+// (ex: "/debug/pprof/"): the index, cmdline, profile, trace, symbol and the named profiles (heap, goroutine...).
+// The profiles support the "debug" and "seconds" arguments, the heap the "gc" argument. An invalid "seconds" is
+// rejected with 400, the duration is limited to MaxPprofDuration.
+// NOTE: the duration of the CPU profile must be lower than the WriteTimeout of the server.
+func PprofHandler(prefix string) fasthttp.RequestHandler {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		name := strings.TrimPrefix(string(ctx.Path()), prefix)
+		args := ctx.QueryArgs()
+		var duration time.Duration
+		if value := args.Peek("seconds"); len(value) > 0 {
+			// As net/http/pprof, the duration must be a positive number of seconds
+			seconds, err := strconv.ParseInt(string(value), 10, 64)
+			if err != nil || seconds <= 0 {
+				ctx.Error("Bad Request: invalid value for seconds", fasthttp.StatusBadRequest)
+				return
+			}
+			if duration = MaxPprofDuration; seconds < int64(MaxPprofDuration/time.Second) {
+				duration = time.Duration(seconds) * time.Second
+			}
+		}
+		debug, _ := strconv.Atoi(string(args.Peek("debug")))
+		ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
+
+		switch name {
+		case "":
+			pprofIndex(ctx, prefix)
+			return
+		case "cmdline":
+			ctx.SetContentType("text/plain; charset=utf-8")
+			ctx.SetBodyString(strings.Join(os.Args, "\x00"))
+			return
+		case "symbol":
+			pprofSymbol(ctx)
+			return
+		case "profile":
+			name = ProfileCPU
+		case "trace":
+		default:
+			if pprof.Lookup(name) == nil {
+				ctx.Error("Unknown profile", fasthttp.StatusNotFound)
+				return
+			}
+		}
+		var buf bytes.Buffer
+		var err error
+		if name == ProfileHeap && len(args.Peek("gc")) == 0 {
+			// As net/http/pprof, the GC is forced only with the gc argument
+			err = pprof.Lookup(ProfileHeap).WriteTo(&buf, debug)
+		} else {
+			err = CaptureProfile(ctx, &buf, name, duration, debug)
+		}
+		if err != nil {
+			log.Error("PprofHandler | Unable to capture the profile [", name, "] | ERR: ", err)
+			ctx.Error("Could not capture the profile: "+err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		if debug > 0 && name != ProfileCPU && name != ProfileTrace {
+			ctx.SetContentType("text/plain; charset=utf-8")
+		} else {
+			ctx.SetContentType("application/octet-stream")
+			ctx.Response.Header.Set(fasthttp.HeaderContentDisposition, ContentDisposition(DispositionAttachment, name))
+		}
+		ctx.SetBody(buf.Bytes())
+	}
+}
+
+// pprofIndex send the HTML list of the profiles
+func pprofIndex(ctx *fasthttp.RequestCtx, prefix string) {
+	var names []string
+	counts := make(map[string]int)
+	for _, profile := range pprof.Profiles() {
+		names = append(names, profile.Name())
+		counts[profile.Name()] = profile.Count()
+	}
+	names = append(names, "cmdline", "profile", "trace")
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html><head><title>" + html.EscapeString(prefix) + "</title></head><body>\n" + html.EscapeString(prefix) + "\n<br><p>Types of profiles available:</p>\n<table>\n<thead><td>Count</td><td>Profile</td></thead>\n")
+	for _, name := range names {
+		count := ""
+		if c, ok := counts[name]; ok {
+			count = strconv.Itoa(c)
+		}
+		link := name + "?debug=1"
+		if name == "profile" || name == "trace" || name == "cmdline" {
+			link = name
+		}
+		buf.WriteString("<tr><td>" + count + "</td><td><a href=\"" + html.EscapeString(link) + "\">" + html.EscapeString(name) + "</a></td></tr>\n")
+	}
+	buf.WriteString("</table>\n<a href=\"goroutine?debug=2\">full goroutine stack dump</a>\n<br>\n<p>Profile Descriptions:</p>\n<ul>\n")
+	for _, name := range names {
+		if description, ok := pprofDescriptions[name]; ok {
+			buf.WriteString("<li><div class=profile-name>" + html.EscapeString(name) + ": </div> " + html.EscapeString(description) + "</li>\n")
+		}
+	}
+	buf.WriteString("</ul>\n</body></html>\n")
+	ctx.SetContentType("text/html; charset=utf-8")
+	ctx.SetBody(buf.Bytes())
+}
+
+// pprofSymbol resolve the program counters of the request (separated by "+", in the body or in the query) in the function names
+func pprofSymbol(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("text/plain; charset=utf-8")
+	var buf bytes.Buffer
+	// The pprof tool only check that num_symbols is greater than 0
+	buf.WriteString("num_symbols: 1\n")
+
+	var input []byte
+	if ctx.IsPost() {
+		input = ctx.PostBody()
+	} else {
+		input = ctx.URI().QueryString()
+	}
+	reader := bufio.NewReader(bytes.NewReader(input))
+	for {
+		word, err := reader.ReadSlice('+')
+		if err == nil {
+			word = word[:len(word)-1]
+		}
+		if pc, parseErr := strconv.ParseUint(string(word), 0, 64); parseErr == nil && pc != 0 {
+			if fn := runtime.FuncForPC(uintptr(pc)); fn != nil {
+				buf.WriteString("0x" + strconv.FormatUint(pc, 16) + " " + fn.Name() + "\n")
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	ctx.SetBody(buf.Bytes())
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPprofHandlerSeconds(t *testing.T) {
+	handler := PprofHandler("/debug/pprof")
+	request := func(uri string) *fasthttp.RequestCtx {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI(uri)
+		handler(&ctx)
+		return &ctx
+	}
+	for _, seconds := range []string{"abc", "0", "-1", "1.5", "99999999999999999999"} {
+		for _, name := range []string{"profile", "trace", "heap"} {
+			if ctx := request("/debug/pprof/" + name + "?seconds=" + seconds); ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+				t.Errorf("%s?seconds=%s: status = %d, expected 400", name, seconds, ctx.Response.StatusCode())
+			}
+		}
+	}
+	if ctx := request("/debug/pprof/goroutine?debug=1"); ctx.Response.StatusCode() != fasthttp.StatusOK || len(ctx.Response.Body()) == 0 {
+		t.Errorf("goroutine: status = %d, %d bytes", ctx.Response.StatusCode(), len(ctx.Response.Body()))
+	}
+	if ctx := request("/debug/pprof/missing"); ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("missing: status = %d, expected 404", ctx.Response.StatusCode())
+	}
+}
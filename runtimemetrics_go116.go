@@ -0,0 +1,91 @@
+//go:build go1.16
+// +build go1.16
+
+package utils
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"time"
+)
+
+// runtimeMetricsPrefixes are the prefixes of the runtime/metrics samples stored in the RuntimeSnapshot
+var runtimeMetricsPrefixes = []string{"/sched/", "/cpu/classes/", "/gc/"}
+
+// schedLatencyMetric is the histogram of the scheduler latencies
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// readRuntimeMetrics return the scalar samples of runtime/metrics and the distribution of the scheduler latencies
+func readRuntimeMetrics() (map[string]float64, *DurationStats) {
+	var samples []metrics.Sample
+	for _, desc := range metrics.All() {
+		for _, prefix := range runtimeMetricsPrefixes {
+			if strings.HasPrefix(desc.Name, prefix) {
+				samples = append(samples, metrics.Sample{Name: desc.Name})
+				break
+			}
+		}
+	}
+	metrics.Read(samples)
+
+	values := make(map[string]float64, len(samples))
+	var schedLatency *DurationStats
+	for _, sample := range samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			values[sample.Name] = float64(sample.Value.Uint64())
+		case metrics.KindFloat64:
+			values[sample.Name] = sample.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			if sample.Name == schedLatencyMetric {
+				stats := histogramDurationStats(sample.Value.Float64Histogram())
+				schedLatency = &stats
+			}
+		}
+	}
+	return values, schedLatency
+}
+
+// histogramDurationStats compute the percentiles of a histogram in seconds. The value of a percentile is the
+// upper bound of its bucket (the lower bound for the last bucket, that is unbounded).
+func histogramDurationStats(h *metrics.Float64Histogram) DurationStats {
+	var stats DurationStats
+	for _, count := range h.Counts {
+		stats.Count += count
+	}
+	if stats.Count == 0 {
+		return stats
+	}
+	bound := func(i int) time.Duration {
+		value := h.Buckets[i+1]
+		if math.IsInf(value, 1) {
+			value = h.Buckets[i]
+		}
+		return time.Duration(value * float64(time.Second))
+	}
+	percentile := func(p float64) time.Duration {
+		rank := uint64(math.Ceil(p * float64(stats.Count)))
+		var total uint64
+		for i, count := range h.Counts {
+			if total += count; total >= rank && count > 0 {
+				return bound(i)
+			}
+		}
+		return bound(len(h.Counts) - 1)
+	}
+	for i, count := range h.Counts {
+		if count > 0 {
+			stats.Min = time.Duration(math.Max(h.Buckets[i], 0) * float64(time.Second))
+			break
+		}
+	}
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			stats.Max = bound(i)
+			break
+		}
+	}
+	stats.P50, stats.P90, stats.P99 = percentile(0.50), percentile(0.90), percentile(0.99)
+	return stats
+}
@@ -0,0 +1,9 @@
+//go:build !go1.16
+// +build !go1.16
+
+package utils
+
+// readRuntimeMetrics return nil, runtime/metrics is available only from go1.16
+func readRuntimeMetrics() (map[string]float64, *DurationStats) {
+	return nil, nil
+}
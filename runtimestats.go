@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DurationStats is the distribution of a set of durations (ex: the GC pauses)
+type DurationStats struct {
+	Count uint64
+	// Total is the sum of the durations, 0 when is not known
+	Total time.Duration
+	Min   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// newDurationStats compute the percentiles (nearest rank) of the given durations, that are sorted in place
+func newDurationStats(durations []time.Duration) DurationStats {
+	stats := DurationStats{Count: uint64(len(durations))}
+	if len(durations) == 0 {
+		return stats
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	for _, d := range durations {
+		stats.Total += d
+	}
+	percentile := func(p float64) time.Duration {
+		i := int(p*float64(len(durations))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		return durations[i]
+	}
+	stats.Min, stats.Max = durations[0], durations[len(durations)-1]
+	stats.P50, stats.P90, stats.P99 = percentile(0.50), percentile(0.90), percentile(0.99)
+	return stats
+}
+
+// GoroutineSample is the number of goroutines at a given time
+type GoroutineSample struct {
+	Time  time.Time
+	Count int
+}
+
+// RuntimeSnapshot contains the stats of the runtime at a given time.
+// The fields of the Monitor are embedded, so the JSON of the snapshot (returned by ExportMetrics) is a superset of the Monitor.
+type RuntimeSnapshot struct {
+	Monitor
+	Time time.Time
+
+	// Heap
+	HeapAlloc    uint64
+	HeapSys      uint64
+	HeapInuse    uint64
+	HeapIdle     uint64
+	HeapReleased uint64
+	HeapObjects  uint64
+	NextGC       uint64
+
+	// Stack
+	StackInuse uint64
+	StackSys   uint64
+
+	// GC
+	LastGC time.Time
+	// GCPauses is the distribution of the recent GC pauses (at most the last 256)
+	GCPauses DurationStats
+	// RecentGCPauses are the recent GC pauses, the most recent first
+	RecentGCPauses []time.Duration
+
+	// Scheduler
+	NumCPU     int
+	GOMAXPROCS int
+	NumCgoCall int64
+	// SchedLatency is the distribution of the time spent by the goroutines in the runnable state before run,
+	// available only with runtime/metrics (go1.16+)
+	SchedLatency *DurationStats
+	// RuntimeMetrics contains the scalar samples of runtime/metrics for the scheduler, the GC and the CPU classes (go1.16+),
+	// ex: "/cpu/classes/gc/total:cpu-seconds". It is nil with the older versions of Go.
+	RuntimeMetrics map[string]float64
+
+	// Process and Host are the stats of the process and of the host read from the DefaultProcFS, nil if not available
+	Process *ProcessStats `json:",omitempty"`
+	Host    *HostStats    `json:",omitempty"`
+
+	// GoroutineHistory is the number of goroutines sampled by the RuntimeRecorder, the oldest first.
+	// It is empty for the snapshots read by ReadRuntimeSnapshot.
+	GoroutineHistory []GoroutineSample
+}
+
+// monitorFromMemStats return the Monitor of the given MemStats
+func monitorFromMemStats(rtm *runtime.MemStats) Monitor {
+	return Monitor{
+		Alloc:         rtm.Alloc,
+		TotalAlloc:    rtm.TotalAlloc,
+		Sys:           rtm.Sys,
+		Mallocs:       rtm.Mallocs,
+		Frees:         rtm.Frees,
+		LiveObjects:   rtm.Mallocs - rtm.Frees,
+		PauseTotalNs:  rtm.PauseTotalNs,
+		MCacheInuse:   rtm.MCacheInuse,
+		GCCPUFraction: rtm.GCCPUFraction,
+		NumGC:         rtm.NumGC,
+		NumGoroutine:  runtime.NumGoroutine(),
+	}
+}
+
+// recentGCPauses return the recent pauses stored in the circular buffer of the MemStats, the most recent first
+func recentGCPauses(rtm *runtime.MemStats) []time.Duration {
+	n := int(rtm.NumGC)
+	if n > len(rtm.PauseNs) {
+		n = len(rtm.PauseNs)
+	}
+	pauses := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		// The most recent pause is at PauseNs[(NumGC+255)%256]
+		pauses = append(pauses, time.Duration(rtm.PauseNs[(int(rtm.NumGC)-1-i+len(rtm.PauseNs))%len(rtm.PauseNs)]))
+	}
+	return pauses
+}
+
+// ReadRuntimeSnapshot return the current stats of the runtime.
+// NOTE: runtime.ReadMemStats stop the world for a short time, avoid to call it in a tight loop
+func ReadRuntimeSnapshot() *RuntimeSnapshot {
+	var rtm runtime.MemStats
+	runtime.ReadMemStats(&rtm)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	s := &RuntimeSnapshot{
+		Monitor:        monitorFromMemStats(&rtm),
+		Time:           time.Now(),
+		HeapAlloc:      rtm.HeapAlloc,
+		HeapSys:        rtm.HeapSys,
+		HeapInuse:      rtm.HeapInuse,
+		HeapIdle:       rtm.HeapIdle,
+		HeapReleased:   rtm.HeapReleased,
+		HeapObjects:    rtm.HeapObjects,
+		NextGC:         rtm.NextGC,
+		StackInuse:     rtm.StackInuse,
+		StackSys:       rtm.StackSys,
+		LastGC:         gc.LastGC,
+		RecentGCPauses: recentGCPauses(&rtm),
+		NumCPU:         runtime.NumCPU(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		NumCgoCall:     runtime.NumCgoCall(),
+	}
+	pauses := make([]time.Duration, len(s.RecentGCPauses))
+	copy(pauses, s.RecentGCPauses)
+	s.GCPauses = newDurationStats(pauses)
+	// The total of the GCStats include also the pauses no more present in the buffer
+	s.GCPauses.Total = gc.PauseTotal
+	s.RuntimeMetrics, s.SchedLatency = readRuntimeMetrics()
+	if DefaultProcFS.Root != "" {
+		var err error
+		if s.Process, err = DefaultProcFS.ProcessStats(); err != nil {
+			log.Debug("ReadRuntimeSnapshot | Unable to read the process stats | ERR: ", err)
+		}
+		if s.Host, err = DefaultProcFS.HostStats(DefaultProcFS.DiskPaths...); err != nil {
+			log.Debug("ReadRuntimeSnapshot | Unable to read the host stats | ERR: ", err)
+		}
+	}
+	return s
+}
+
+// RuntimeDelta is the difference between two RuntimeSnapshot
+type RuntimeDelta struct {
+	Interval time.Duration
+	NumGC    uint32
+	// GCPauseTotal is the time spent in the GC pauses during the interval
+	GCPauseTotal time.Duration
+	TotalAlloc   uint64
+	Mallocs      uint64
+	Frees        uint64
+	// AllocRate is the number of bytes allocated per second
+	AllocRate   float64
+	HeapInuse   int64
+	HeapObjects int64
+	StackInuse  int64
+	Goroutines  int
+	NumCgoCall  int64
+	// CPUUser, CPUSystem, ReadBytes and WriteBytes are the resources used by the process, 0 if the process stats are not available
+	CPUUser    time.Duration
+	CPUSystem  time.Duration
+	ReadBytes  uint64
+	WriteBytes uint64
+	// RuntimeMetrics contains the difference of the samples present in both the snapshots (ex: the CPU seconds used by the GC)
+	RuntimeMetrics map[string]float64
+}
+
+// Delta return the difference between the snapshot and a previous one
+func (s *RuntimeSnapshot) Delta(prev *RuntimeSnapshot) *RuntimeDelta {
+	d := &RuntimeDelta{
+		Interval:     s.Time.Sub(prev.Time),
+		NumGC:        s.NumGC - prev.NumGC,
+		GCPauseTotal: time.Duration(s.PauseTotalNs - prev.PauseTotalNs),
+		TotalAlloc:   s.TotalAlloc - prev.TotalAlloc,
+		Mallocs:      s.Mallocs - prev.Mallocs,
+		Frees:        s.Frees - prev.Frees,
+		HeapInuse:    int64(s.HeapInuse) - int64(prev.HeapInuse),
+		HeapObjects:  int64(s.HeapObjects) - int64(prev.HeapObjects),
+		StackInuse:   int64(s.StackInuse) - int64(prev.StackInuse),
+		Goroutines:   s.NumGoroutine - prev.NumGoroutine,
+		NumCgoCall:   s.NumCgoCall - prev.NumCgoCall,
+	}
+	if d.Interval > 0 {
+		d.AllocRate = float64(d.TotalAlloc) / d.Interval.Seconds()
+	}
+	if s.Process != nil && prev.Process != nil {
+		d.CPUUser = s.Process.CPUUser - prev.Process.CPUUser
+		d.CPUSystem = s.Process.CPUSystem - prev.Process.CPUSystem
+		d.ReadBytes = s.Process.ReadBytes - prev.Process.ReadBytes
+		d.WriteBytes = s.Process.WriteBytes - prev.Process.WriteBytes
+	}
+	if s.RuntimeMetrics != nil && prev.RuntimeMetrics != nil {
+		d.RuntimeMetrics = make(map[string]float64, len(s.RuntimeMetrics))
+		for name, value := range s.RuntimeMetrics {
+			if old, ok := prev.RuntimeMetrics[name]; ok {
+				d.RuntimeMetrics[name] = value - old
+			}
+		}
+	}
+	return d
+}
+
+// RuntimeRecorder sample periodically the runtime, keeping the history of the number of goroutines and the last snapshot
+type RuntimeRecorder struct {
+	interval time.Duration
+	size     int
+
+	mu      sync.RWMutex
+	history []GoroutineSample
+	last    *RuntimeSnapshot
+}
+
+// NewRuntimeRecorder return a recorder that sample the runtime every interval, keeping the last size samples of goroutines
+func NewRuntimeRecorder(interval time.Duration, size int) *RuntimeRecorder {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if size <= 0 {
+		size = 360
+	}
+	return &RuntimeRecorder{interval: interval, size: size}
+}
+
+// Run sample the runtime until the context is done, return the context error
+func (r *RuntimeRecorder) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	r.Snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			r.Snapshot()
+		}
+	}
+}
+
+// record add the number of goroutines to the history, removing the oldest samples
+func (r *RuntimeRecorder) record(s *RuntimeSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = append(r.history, GoroutineSample{Time: s.Time, Count: s.NumGoroutine})
+	if len(r.history) > r.size {
+		// Copy in order to release the old array
+		r.history = append([]GoroutineSample(nil), r.history[len(r.history)-r.size:]...)
+	}
+	s.GoroutineHistory = append([]GoroutineSample(nil), r.history...)
+	r.last = s
+}
+
+// Snapshot read a new snapshot and add it to the history, the snapshot contains the goroutine history
+func (r *RuntimeRecorder) Snapshot() *RuntimeSnapshot {
+	s := ReadRuntimeSnapshot()
+	r.record(s)
+	return s
+}
+
+// Last return the last snapshot taken, nil if none
+func (r *RuntimeRecorder) Last() *RuntimeSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last
+}
+
+// GoroutineHistory return a copy of the samples of the number of goroutines, the oldest first
+func (r *RuntimeRecorder) GoroutineHistory() []GoroutineSample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]GoroutineSample(nil), r.history...)
+}
@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// equalFoldRune verify if the two runes are equal under the Unicode simple case folding
+func equalFoldRune(r1, r2 rune) bool {
+	if r1 == r2 {
+		return true
+	}
+	if r1 < utf8.RuneSelf && r2 < utf8.RuneSelf { // ASCII fast path
+		if 'A' <= r1 && r1 <= 'Z' {
+			r1 += 'a' - 'A'
+		}
+		if 'A' <= r2 && r2 <= 'Z' {
+			r2 += 'a' - 'A'
+		}
+		return r1 == r2
+	}
+	for r := unicode.SimpleFold(r1); r != r1; r = unicode.SimpleFold(r) {
+		if r == r2 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixFold verify if s start with prefix ignoring the case
+func hasPrefixFold(s, prefix []byte) bool {
+	for len(prefix) > 0 {
+		if len(s) == 0 {
+			return false
+		}
+		r1, n1 := utf8.DecodeRune(s)
+		r2, n2 := utf8.DecodeRune(prefix)
+		if !equalFoldRune(r1, r2) {
+			return false
+		}
+		s, prefix = s[n1:], prefix[n2:]
+	}
+	return true
+}
+
+// IndexFoldBytes is the []byte version of IndexFold, used for search in a MappedFile.Bytes() without copy it
+func IndexFoldBytes(s, substr []byte) int {
+	if len(substr) == 0 {
+		return 0
+	}
+	for i := 0; i < len(s); {
+		if hasPrefixFold(s[i:], substr) {
+			return i
+		}
+		_, size := utf8.DecodeRune(s[i:])
+		i += size
+	}
+	return -1
+}
+
+// hasPrefixFoldString verify if s start with prefix ignoring the case
+func hasPrefixFoldString(s, prefix string) bool {
+	for len(prefix) > 0 {
+		if len(s) == 0 {
+			return false
+		}
+		r1, n1 := utf8.DecodeRuneInString(s)
+		r2, n2 := utf8.DecodeRuneInString(prefix)
+		if !equalFoldRune(r1, r2) {
+			return false
+		}
+		s, prefix = s[n1:], prefix[n2:]
+	}
+	return true
+}
+
+// IndexFold return the index of the first occurrence of substr in s, -1 if not present.
+// The strings are compared using the Unicode simple case folding, without allocate memory.
+func IndexFold(s, substr string) int {
+	if len(substr) == 0 {
+		return 0
+	}
+	for i := 0; i < len(s); {
+		if hasPrefixFoldString(s[i:], substr) {
+			return i
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return -1
+}
+
+// ContainsFold verify if substr is present in s, using the Unicode simple case folding
+func ContainsFold(s, substr string) bool {
+	return IndexFold(s, substr) != -1
+}
+
+// ContainsFoldBytes is the []byte version of ContainsFold
+func ContainsFoldBytes(s, substr []byte) bool {
+	return IndexFoldBytes(s, substr) != -1
+}
+
+// CompareData verify if the given target is present in the data
+func CompareData(data, toFind string) bool {
+	return strings.Contains(data, toFind)
+}
+
+// CompareDataBytes is the []byte version of CompareData, ex: CompareDataBytes(mapped.Bytes(), "target") scan a
+// MappedFile without copy it in the heap
+func CompareDataBytes(data []byte, toFind string) bool {
+	return bytes.Contains(data, []byte(toFind))
+}
+
+// CompareDataInsensitive verify if the given target is present in the data, ignoring the case of both the strings.
+// The Unicode simple case folding is used, so there is no need to lower the target before call the function.
+func CompareDataInsensitive(data, toFindLower string) bool {
+	return ContainsFold(data, toFindLower)
+}
+
+// CompareDataInsensitiveBytes is the []byte version of CompareDataInsensitive
+func CompareDataInsensitiveBytes(data []byte, toFindLower string) bool {
+	return ContainsFoldBytes(data, []byte(toFindLower))
+}
+
+// foldRune return the representative of the case folding orbit of the rune (the smallest one), used as key of the Matcher
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+	smallest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < smallest {
+			smallest = f
+		}
+	}
+	return smallest
+}
+
+// MatchResult is an occurrence of a pattern found by the Matcher
+type MatchResult struct {
+	// Pattern is the index of the pattern in the list given to NewMatcher
+	Pattern int
+	// Start and End are the byte offsets of the occurrence in the text
+	Start, End int
+}
+
+// acState is a state of the Aho-Corasick automaton
+type acState struct {
+	next map[rune]int32
+	fail int32
+	// output is the index of the pattern ending in this state, -1 if none
+	output int32
+	// dictLink is the nearest state reachable by the fail links that has an output, -1 if none
+	dictLink int32
+	// depth is the length in runes of the path from the root
+	depth int32
+}
+
+// Matcher search many patterns at once using the Aho-Corasick automaton: the text is scanned only once, whatever the number of patterns.
+// The Matcher is immutable after the creation, so it is safe for concurrent use.
+type Matcher struct {
+	states   []acState
+	patterns []string
+	fold     bool
+}
+
+// NewMatcher build the automaton for the given patterns. If fold is true, the case is ignored (Unicode simple case folding).
+// The empty patterns are ignored.
+func NewMatcher(patterns []string, fold bool) *Matcher {
+	m := &Matcher{patterns: patterns, fold: fold}
+	m.states = append(m.states, acState{next: map[rune]int32{}, output: -1, dictLink: -1})
+	for i, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		state := int32(0)
+		for _, r := range pattern {
+			if fold {
+				r = foldRune(r)
+			}
+			next, ok := m.states[state].next[r]
+			if !ok {
+				next = int32(len(m.states))
+				m.states = append(m.states, acState{next: map[rune]int32{}, output: -1, dictLink: -1, depth: m.states[state].depth + 1})
+				m.states[state].next[r] = next
+			}
+			state = next
+		}
+		if m.states[state].output == -1 { // Keep the first of the duplicated patterns
+			m.states[state].output = int32(i)
+		}
+	}
+
+	// Compute the fail links with a breadth first visit
+	queue := make([]int32, 0, len(m.states))
+	for _, child := range m.states[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.states[state].next {
+			fail := m.states[state].fail
+			for {
+				if next, ok := m.states[fail].next[r]; ok {
+					m.states[child].fail = next
+					break
+				}
+				if fail == 0 {
+					m.states[child].fail = 0
+					break
+				}
+				fail = m.states[fail].fail
+			}
+			failState := m.states[child].fail
+			if m.states[failState].output != -1 {
+				m.states[child].dictLink = failState
+			} else {
+				m.states[child].dictLink = m.states[failState].dictLink
+			}
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// step return the state reached from the given one reading the rune
+func (m *Matcher) step(state int32, r rune) int32 {
+	if m.fold {
+		r = foldRune(r)
+	}
+	for {
+		if next, ok := m.states[state].next[r]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = m.states[state].fail
+	}
+}
+
+// Index return the first occurrence (by end position) of any of the patterns in the text, without allocate memory.
+// The returned bool is false if no pattern is present.
+func (m *Matcher) Index(text string) (MatchResult, bool) {
+	state := int32(0)
+	for i, r := range text {
+		state = m.step(state, r)
+		found := state
+		if m.states[found].output == -1 {
+			found = m.states[found].dictLink
+		}
+		if found != -1 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			end := i + size
+			return MatchResult{Pattern: int(m.states[found].output), Start: m.start(text, end, m.states[found].depth), End: end}, true
+		}
+	}
+	return MatchResult{}, false
+}
+
+// Match verify if any of the patterns is present in the text
+func (m *Matcher) Match(text string) bool {
+	_, found := m.Index(text)
+	return found
+}
+
+// FindAll return all the (possibly overlapping) occurrences of the patterns in the text, ordered by end position
+func (m *Matcher) FindAll(text string) []MatchResult {
+	var results []MatchResult
+	state := int32(0)
+	for i, r := range text {
+		state = m.step(state, r)
+		_, size := utf8.DecodeRuneInString(text[i:])
+		end := i + size
+		for found := state; found != -1; found = m.states[found].dictLink {
+			if m.states[found].output != -1 {
+				results = append(results, MatchResult{Pattern: int(m.states[found].output), Start: m.start(text, end, m.states[found].depth), End: end})
+			}
+		}
+	}
+	return results
+}
+
+// start return the byte offset of the occurrence of 'runes' runes that end at the given offset
+func (m *Matcher) start(text string, end int, runes int32) int {
+	for ; runes > 0; runes-- {
+		_, size := utf8.DecodeLastRuneInString(text[:end])
+		end -= size
+	}
+	return end
+}
+
+// IndexBytes is the []byte version of Index, used for search in a MappedFile.Bytes() without copy it
+func (m *Matcher) IndexBytes(text []byte) (MatchResult, bool) {
+	state := int32(0)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		i += size
+		state = m.step(state, r)
+		found := state
+		if m.states[found].output == -1 {
+			found = m.states[found].dictLink
+		}
+		if found != -1 {
+			return MatchResult{Pattern: int(m.states[found].output), Start: m.startBytes(text, i, m.states[found].depth), End: i}, true
+		}
+	}
+	return MatchResult{}, false
+}
+
+// MatchBytes is the []byte version of Match
+func (m *Matcher) MatchBytes(text []byte) bool {
+	_, found := m.IndexBytes(text)
+	return found
+}
+
+// FindAllBytes is the []byte version of FindAll
+func (m *Matcher) FindAllBytes(text []byte) []MatchResult {
+	var results []MatchResult
+	state := int32(0)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		i += size
+		state = m.step(state, r)
+		for found := state; found != -1; found = m.states[found].dictLink {
+			if m.states[found].output != -1 {
+				results = append(results, MatchResult{Pattern: int(m.states[found].output), Start: m.startBytes(text, i, m.states[found].depth), End: i})
+			}
+		}
+	}
+	return results
+}
+
+// startBytes is the []byte version of start
+func (m *Matcher) startBytes(text []byte, end int, runes int32) int {
+	for ; runes > 0; runes-- {
+		_, size := utf8.DecodeLastRune(text[:end])
+		end -= size
+	}
+	return end
+}
+
+// Patterns return the patterns searched by the Matcher
+func (m *Matcher) Patterns() []string {
+	return m.patterns
+}
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SecurityPolicy contains the security headers of the HTTP responses and the CORS rules.
+// The empty fields are not sent.
+type SecurityPolicy struct {
+	// CSP contains the directives of the Content-Security-Policy, ex: {"default-src": {"'self'"}, "upgrade-insecure-requests": nil}
+	CSP map[string][]string
+	// CSPReportOnly send the CSP in the Content-Security-Policy-Report-Only header, for test the policy without enforce it
+	CSPReportOnly bool
+
+	// AllowedOrigins are the origins allowed to call the server (CORS), "*" for every origin.
+	// A leading wildcard allow the subdomains, ex: "https://*.example.com"
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in the CORS requests, GET, HEAD and POST if empty
+	AllowedMethods []string
+	// AllowedHeaders are the headers allowed in the CORS requests, "*" for every header
+	AllowedHeaders []string
+	// ExposedHeaders are the headers of the responses readable by the client
+	ExposedHeaders []string
+	// AllowCredentials allow the CORS requests with cookies. With "*" in AllowedOrigins the origin of the request is echoed.
+	AllowCredentials bool
+	// CORSMaxAge is the time that the client can cache the response of the preflight request
+	CORSMaxAge time.Duration
+
+	// FrameOptions is the X-Frame-Options header, ex: "DENY"
+	FrameOptions string
+	// NoSniff send the "X-Content-Type-Options: nosniff" header
+	NoSniff bool
+	// ReferrerPolicy is the Referrer-Policy header, ex: "strict-origin-when-cross-origin"
+	ReferrerPolicy string
+	// PermissionsPolicy contains the allowlist of the features, ex: {"camera": nil, "geolocation": {"self"}}
+	PermissionsPolicy map[string][]string
+	// CrossOriginOpenerPolicy is the Cross-Origin-Opener-Policy header, ex: "same-origin"
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy is the Cross-Origin-Embedder-Policy header, ex: "require-corp"
+	CrossOriginEmbedderPolicy string
+	// CrossOriginResourcePolicy is the Cross-Origin-Resource-Policy header, ex: "same-origin"
+	CrossOriginResourcePolicy string
+
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header, sent only on the TLS connections. 0 disable the header.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// HSTSPreload ask the inclusion in the preload list of the browsers (require HSTSIncludeSubdomains and a max-age of at least 1 year)
+	HSTSPreload bool
+}
+
+// DefaultSecurityPolicy return a strict policy, that does not allow any cross-origin request
+func DefaultSecurityPolicy() *SecurityPolicy {
+	return &SecurityPolicy{
+		CSP: map[string][]string{
+			"default-src":     {"'self'"},
+			"frame-ancestors": {"'none'"},
+			"object-src":      {"'none'"},
+			"base-uri":        {"'self'"},
+		},
+		FrameOptions:              "DENY",
+		NoSniff:                   true,
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		PermissionsPolicy:         map[string][]string{"camera": nil, "microphone": nil, "geolocation": nil},
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+		HSTSMaxAge:                2 * 365 * 24 * time.Hour,
+		HSTSIncludeSubdomains:     true,
+	}
+}
+
+// legacySecurityPolicy is the policy applied by SecureRequest
+var legacySecurityPolicy = &SecurityPolicy{
+	AllowedOrigins:        []string{"*"},
+	FrameOptions:          "DENY",
+	NoSniff:               true,
+	HSTSMaxAge:            63072000 * time.Second,
+	HSTSIncludeSubdomains: true,
+}
+
+// legacySSLSecurityPolicy is the policy applied by SecureRequest for the ssl connections, that upgrade the insecure requests
+var legacySSLSecurityPolicy = func() *SecurityPolicy {
+	policy := *legacySecurityPolicy
+	policy.CSP = map[string][]string{"upgrade-insecure-requests": nil}
+	return &policy
+}()
+
+// CSPHeader return the value of the Content-Security-Policy header, the directives are sorted by name
+func (p *SecurityPolicy) CSPHeader() string {
+	directives := make([]string, 0, len(p.CSP))
+	for name, values := range p.CSP {
+		directives = append(directives, strings.TrimSpace(name+" "+strings.Join(values, " ")))
+	}
+	sort.Strings(directives)
+	return strings.Join(directives, "; ")
+}
+
+// PermissionsPolicyHeader return the value of the Permissions-Policy header, the features are sorted by name.
+// The origins are quoted, except the keywords "self" and "*".
+func (p *SecurityPolicy) PermissionsPolicyHeader() string {
+	features := make([]string, 0, len(p.PermissionsPolicy))
+	for feature, allowlist := range p.PermissionsPolicy {
+		origins := make([]string, len(allowlist))
+		for i, origin := range allowlist {
+			if origin == "self" || origin == "*" || strings.HasPrefix(origin, `"`) {
+				origins[i] = origin
+			} else {
+				origins[i] = strconv.Quote(origin)
+			}
+		}
+		if len(origins) == 1 && origins[0] == "*" {
+			features = append(features, feature+"=*")
+		} else {
+			features = append(features, feature+"=("+strings.Join(origins, " ")+")")
+		}
+	}
+	sort.Strings(features)
+	return strings.Join(features, ", ")
+}
+
+// HSTSHeader return the value of the Strict-Transport-Security header, empty if disabled
+func (p *SecurityPolicy) HSTSHeader() string {
+	if p.HSTSMaxAge <= 0 {
+		return ""
+	}
+	header := "max-age=" + strconv.FormatInt(int64(p.HSTSMaxAge/time.Second), 10)
+	if p.HSTSIncludeSubdomains {
+		header += "; includeSubDomains"
+	}
+	if p.HSTSPreload {
+		header += "; preload"
+	}
+	return header
+}
+
+// Apply set the security headers and the CORS headers in the response.
+// ssl enable the headers reserved to the TLS connections (HSTS), it is always enabled if the connection of ctx is TLS.
+func (p *SecurityPolicy) Apply(ctx *fasthttp.RequestCtx, ssl bool) {
+	header := &ctx.Response.Header
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			header.Set(key, value)
+		}
+	}
+	if p.NoSniff {
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	setIfNotEmpty("X-Frame-Options", p.FrameOptions)
+	if p.CSPReportOnly {
+		setIfNotEmpty("Content-Security-Policy-Report-Only", p.CSPHeader())
+	} else {
+		setIfNotEmpty("Content-Security-Policy", p.CSPHeader())
+	}
+	setIfNotEmpty("Referrer-Policy", p.ReferrerPolicy)
+	setIfNotEmpty("Permissions-Policy", p.PermissionsPolicyHeader())
+	setIfNotEmpty("Cross-Origin-Opener-Policy", p.CrossOriginOpenerPolicy)
+	setIfNotEmpty("Cross-Origin-Embedder-Policy", p.CrossOriginEmbedderPolicy)
+	setIfNotEmpty("Cross-Origin-Resource-Policy", p.CrossOriginResourcePolicy)
+	if ssl || ctx.IsTLS() {
+		setIfNotEmpty("Strict-Transport-Security", p.HSTSHeader())
+	}
+	p.applyCORS(ctx)
+}
+
+// applyCORS set the CORS headers of the response, if the origin of the request is allowed
+func (p *SecurityPolicy) applyCORS(ctx *fasthttp.RequestCtx) {
+	allowOrigin := p.allowOrigin(ctx)
+	if allowOrigin == "" {
+		return
+	}
+	header := &ctx.Response.Header
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(p.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+	}
+}
+
+// allowOrigin return the value of the Access-Control-Allow-Origin header for the request, empty if the origin is not allowed.
+// When the value depends on the origin, "Origin" is added to the Vary header.
+func (p *SecurityPolicy) allowOrigin(ctx *fasthttp.RequestCtx) string {
+	if len(p.AllowedOrigins) == 0 {
+		return ""
+	}
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			if !p.AllowCredentials {
+				return "*"
+			}
+			// The browsers refuse "*" for the requests with credentials
+			if origin == "" {
+				return ""
+			}
+			addVary(&ctx.Response.Header, "Origin")
+			return origin
+		}
+	}
+	addVary(&ctx.Response.Header, "Origin")
+	if origin != "" && p.originAllowed(origin) {
+		return origin
+	}
+	return ""
+}
+
+// originAllowed return true if the origin is in AllowedOrigins, also using the subdomain wildcard
+func (p *SecurityPolicy) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == origin {
+			return true
+		}
+		// https://*.example.com allow https://api.example.com but not https://example.com
+		if i := strings.Index(allowed, "://*."); i != -1 {
+			scheme, domain := allowed[:i+3], allowed[i+4:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) && len(origin) > len(scheme)+len(domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowedMethods return the methods allowed in the CORS requests
+func (p *SecurityPolicy) allowedMethods() []string {
+	if len(p.AllowedMethods) == 0 {
+		return []string{"GET", "HEAD", "POST"}
+	}
+	return p.AllowedMethods
+}
+
+// headerAllowed return true if the header can be sent in a CORS request
+func (p *SecurityPolicy) headerAllowed(name string) bool {
+	for _, allowed := range p.AllowedHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleOptions answer the OPTIONS requests, returning true if the request was handled.
+// The CORS preflight requests (with Origin and Access-Control-Request-Method) are answered with the allowed methods and headers,
+// or with 403 if the origin, the method or a header is not allowed; the other OPTIONS requests with the Allow header.
+// It can be used without Apply: the CORS headers are set if not already present.
+func (p *SecurityPolicy) HandleOptions(ctx *fasthttp.RequestCtx) bool {
+	if !ctx.IsOptions() {
+		return false
+	}
+	header := &ctx.Response.Header
+	methods := p.allowedMethods()
+	requestMethod := string(ctx.Request.Header.Peek("Access-Control-Request-Method"))
+	if len(ctx.Request.Header.Peek("Origin")) == 0 || requestMethod == "" {
+		header.Set(fasthttp.HeaderAllow, strings.Join(append([]string{"OPTIONS"}, methods...), ", "))
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+		return true
+	}
+	if len(header.Peek("Access-Control-Allow-Origin")) == 0 {
+		p.applyCORS(ctx)
+	}
+	addVary(header, "Access-Control-Request-Method")
+	addVary(header, "Access-Control-Request-Headers")
+	if len(header.Peek("Access-Control-Allow-Origin")) == 0 {
+		ctx.Error("CORS origin not allowed", fasthttp.StatusForbidden)
+		return true
+	}
+	methodAllowed := false
+	for _, method := range methods {
+		if strings.EqualFold(method, requestMethod) {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		ctx.Error("CORS method not allowed", fasthttp.StatusForbidden)
+		return true
+	}
+	requestHeaders := string(ctx.Request.Header.Peek("Access-Control-Request-Headers"))
+	for _, name := range strings.Split(requestHeaders, ",") {
+		if name = strings.TrimSpace(name); name != "" && !p.headerAllowed(name) {
+			ctx.Error("CORS header "+name+" not allowed", fasthttp.StatusForbidden)
+			return true
+		}
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if strings.TrimSpace(requestHeaders) != "" {
+		// Echo the requested headers: they are all allowed, and "*" is not valid with the credentials
+		header.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if p.CORSMaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(p.CORSMaxAge/time.Second), 10))
+	}
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+	return true
+}
+
+// SecurityMiddleware apply the policy to every response and answer the OPTIONS requests (CORS preflight) without call the handler.
+// The headers are set before calling the handler, so that it can override them.
+func SecurityMiddleware(policy *SecurityPolicy, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		policy.Apply(ctx, false)
+		if policy.HandleOptions(ctx) {
+			return
+		}
+		handler(ctx)
+	}
+}
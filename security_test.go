@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// preflightRequest return a CORS preflight request from the given origin
+func preflightRequest(origin string) *fasthttp.RequestCtx {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod("OPTIONS")
+	ctx.Request.SetRequestURI("/api")
+	ctx.Request.Header.Set("Origin", origin)
+	ctx.Request.Header.Set("Access-Control-Request-Method", "PUT")
+	ctx.Request.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	return &ctx
+}
+
+func TestHandleOptionsWithoutApply(t *testing.T) {
+	policy := &SecurityPolicy{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+	}
+	// The result must be the same with and without Apply
+	for _, apply := range []bool{false, true} {
+		ctx := preflightRequest("https://api.example.com")
+		if apply {
+			policy.Apply(ctx, false)
+		}
+		if !policy.HandleOptions(ctx) {
+			t.Fatal("preflight request not handled")
+		}
+		checkHeaders(t, ctx, fasthttp.StatusNoContent, "Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers")
+		if origin := string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")); origin != "https://api.example.com" {
+			t.Errorf("apply %v: Access-Control-Allow-Origin = %q", apply, origin)
+		}
+		if vary := string(ctx.Response.Header.Peek(fasthttp.HeaderVary)); vary != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+			t.Errorf("apply %v: Vary = %q", apply, vary)
+		}
+
+		ctx = preflightRequest("https://example.org")
+		if apply {
+			policy.Apply(ctx, false)
+		}
+		if !policy.HandleOptions(ctx) || ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+			t.Errorf("apply %v: status = %d for a not allowed origin, expected 403", apply, ctx.Response.StatusCode())
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxDistance is the max edit distance used by SpellCheck for find a suggestion
+const DefaultMaxDistance = 4
+
+// Suggestion is a word of the dictionary similar to the one to correct
+type Suggestion struct {
+	Word     string
+	Distance float64
+}
+
+// spellEntry is a word of the dictionary, the runes are kept in order to avoid to convert the word on every lookup
+type spellEntry struct {
+	word  string
+	runes []rune
+}
+
+// SpellChecker is delegated to find the words of a dictionary similar to a given one.
+// The dictionary is loaded only once and the SpellChecker is safe for concurrent use.
+type SpellChecker struct {
+	mu      sync.RWMutex
+	entries []spellEntry
+	// metric is the distance used for compare the words, nil for the (optimized) Levenshtein distance
+	metric Metric
+}
+
+// NewSpellChecker load the dictionary from the given reader, one word per line.
+// There is no limit on the number of words or on the length of the lines.
+func NewSpellChecker(r io.Reader) (*SpellChecker, error) {
+	s := &SpellChecker{}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		s.Add(line)
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewSpellCheckerFromFile load the dictionary from the given file, one word per line
+func NewSpellCheckerFromFile(filename string) (*SpellChecker, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, newFileError("open", filename, err)
+	}
+	defer file.Close()
+	return NewSpellChecker(file)
+}
+
+// Add insert the given words in the dictionary, the empty words are ignored
+func (s *SpellChecker) Add(words ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			s.entries = append(s.entries, spellEntry{word: word, runes: []rune(word)})
+		}
+	}
+}
+
+// SetMetric change the distance used for compare the words (ex: DamerauLevenshteinMetric{} or JaroWinklerMetric{}).
+// A nil metric restore the default Levenshtein distance.
+func (s *SpellChecker) SetMetric(metric Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metric = metric
+}
+
+// Len return the number of words of the dictionary
+func (s *SpellChecker) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Suggest return the (at most) k words of the dictionary nearest to the given one, with an edit distance not greater than maxDistance.
+// The suggestions are sorted by distance, the words with the same distance keep the order of the dictionary.
+// If k is not positive, all the words within maxDistance are returned.
+func (s *SpellChecker) Suggest(word string, k int, maxDistance float64) []Suggestion {
+	s.mu.RLock()
+	metric := s.metric
+	s.mu.RUnlock()
+	return s.SuggestMetric(word, k, maxDistance, metric)
+}
+
+// SuggestMetric is like Suggest, but compare the words using the given metric instead of the one of the SpellChecker
+func (s *SpellChecker) SuggestMetric(word string, k int, maxDistance float64, metric Metric) []Suggestion {
+	var suggestions []Suggestion
+	s.mu.RLock()
+	if metric == nil {
+		target := []rune(word)
+		limit := int(maxDistance)
+		// Reused between the words in order to avoid an allocation for every comparison
+		cache := make([]int, len(target)+1)
+		for i := range s.entries {
+			if distance := levenshteinBounded(s.entries[i].runes, target, limit, cache); distance <= limit {
+				suggestions = append(suggestions, Suggestion{Word: s.entries[i].word, Distance: float64(distance)})
+			}
+		}
+	} else {
+		for i := range s.entries {
+			if distance := metric.Distance(s.entries[i].word, word); distance <= maxDistance {
+				suggestions = append(suggestions, Suggestion{Word: s.entries[i].word, Distance: distance})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Distance < suggestions[j].Distance
+	})
+	if k > 0 && len(suggestions) > k {
+		suggestions = suggestions[:k]
+	}
+	return suggestions
+}
+
+// Correct return the word of the dictionary nearest to the given one (up to DefaultMaxDistance), an empty string if not found.
+// NOTE: DefaultMaxDistance does not filter anything for the metrics normalized in [0, 1], like JaroWinklerMetric.
+func (s *SpellChecker) Correct(word string) string {
+	if suggestions := s.Suggest(word, 1, DefaultMaxDistance); len(suggestions) > 0 {
+		return suggestions[0].Word
+	}
+	return ""
+}
+
+// levenshteinBounded return the edit distance between a and b, or limit+1 if the distance is greater than limit.
+// The cache must have len(b)+1 elements.
+func levenshteinBounded(a, b []rune, limit int, cache []int) int {
+	if diff := len(a) - len(b); diff > limit || -diff > limit {
+		return limit + 1
+	}
+	for j := range cache {
+		cache[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := cache[0]
+		cache[0] = i
+		rowMin := cache[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current := cache[j]
+			cache[j] = min3(cache[j]+1, cache[j-1]+1, prev+cost)
+			prev = current
+			if cache[j] < rowMin {
+				rowMin = cache[j]
+			}
+		}
+		// The distance can only grow in the next rows
+		if rowMin > limit {
+			return limit + 1
+		}
+	}
+	if cache[len(b)] > limit {
+		return limit + 1
+	}
+	return cache[len(b)]
+}
+
+// spellCheckers contains the dictionaries already loaded by SpellCheck, by file path
+var spellCheckers sync.Map
+
+// spellCheckerCache is a dictionary loaded by SpellCheck, with the modification time of the file
+type spellCheckerCache struct {
+	checker *SpellChecker
+	modTime int64
+}
+
+// SpellCheck return the word of the dictionary file nearest to the given one.
+// The dictionary is loaded at the first call and reloaded only when the file change.
+func SpellCheck(filepath, wrongword string) string {
+	return SpellCheckMetric(filepath, wrongword, nil)
+}
+
+// SpellCheckMetric is like SpellCheck, but compare the words using the given metric (nil for the Levenshtein distance)
+func SpellCheckMetric(filepath, wrongword string, metric Metric) string {
+	checker := loadSpellChecker(filepath)
+	if checker == nil {
+		return ""
+	}
+	if suggestions := checker.SuggestMetric(wrongword, 1, DefaultMaxDistance, metric); len(suggestions) > 0 {
+		return suggestions[0].Word
+	}
+	return ""
+}
+
+// loadSpellChecker return the SpellChecker of the given dictionary, loading the file only if changed since the last call
+func loadSpellChecker(filepath string) *SpellChecker {
+	modTime, err := GetFileModificationE(filepath)
+	if err != nil {
+		log.Error("SpellCheck | Unable to read dictionary [", filepath, "] | ERR: ", err)
+		return nil
+	}
+	if cached, ok := spellCheckers.Load(filepath); ok && cached.(*spellCheckerCache).modTime == modTime {
+		return cached.(*spellCheckerCache).checker
+	}
+	log.Debug("Reading data from [", filepath, "]")
+	checker, err := NewSpellCheckerFromFile(filepath)
+	if err != nil {
+		log.Error("SpellCheck | Unable to load dictionary [", filepath, "] | ERR: ", err)
+		return nil
+	}
+	spellCheckers.Store(filepath, &spellCheckerCache{checker: checker, modTime: modTime})
+	return checker
+}
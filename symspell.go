@@ -0,0 +1,348 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// symSpellMagic identify the files created by SymSpell.WriteTo
+const symSpellMagic = "SYMS\x01"
+
+const (
+	// symSpellMaxDistance and symSpellMaxPrefixLength are the max parameters accepted by ReadSymSpell: the number of
+	// deletes of a word grow exponentially with them
+	symSpellMaxDistance     = 8
+	symSpellMaxPrefixLength = 32
+)
+
+// DefaultSymSpellPrefixLength is the number of runes of the words used for generate the deletes.
+// A short prefix reduce the memory used by the index, the candidates are always verified on the whole word.
+const DefaultSymSpellPrefixLength = 7
+
+// SymSpell is a symmetric delete index for the fuzzy lookup using the Levenshtein distance.
+// Every word is indexed by the strings obtained deleting up to MaxDistance runes from its prefix: at lookup time only the words that
+// share a delete with the word to search are compared, so the lookup cost does not depend on the size of the dictionary.
+// The index can not be modified after the creation, so it is safe for concurrent use.
+// NOTE: the SymSpell is the index to use for the sub-millisecond lookups (tens of microseconds with 100k words at distance 2,
+// see BenchmarkFuzzySearch), at the cost of more memory and of a slower creation than the BKTree.
+type SymSpell struct {
+	maxDistance  int
+	prefixLength int
+	words        []string
+	runes        [][]rune
+	// keys contains the sorted hash of the deletes, ids the index of the word that generated the delete
+	keys []uint64
+	ids  []int32
+}
+
+// NewSymSpell create the index of the given words, able to find the words within maxDistance.
+// If prefixLength is not positive DefaultSymSpellPrefixLength is used.
+func NewSymSpell(maxDistance, prefixLength int, words []string) *SymSpell {
+	if prefixLength <= 0 {
+		prefixLength = DefaultSymSpellPrefixLength
+	}
+	if prefixLength <= maxDistance {
+		prefixLength = maxDistance + 1
+	}
+	s := &SymSpell{maxDistance: maxDistance, prefixLength: prefixLength}
+	seen := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word == "" {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		s.words = append(s.words, word)
+	}
+
+	type entry struct {
+		key uint64
+		id  int32
+	}
+	var entries []entry
+	s.runes = make([][]rune, len(s.words))
+	for id, word := range s.words {
+		s.runes[id] = []rune(word)
+		s.deletes(s.runes[id], func(key uint64) {
+			entries = append(entries, entry{key: key, id: int32(id)})
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].id < entries[j].id
+	})
+	s.keys = make([]uint64, len(entries))
+	s.ids = make([]int32, len(entries))
+	for i := range entries {
+		s.keys[i], s.ids[i] = entries[i].key, entries[i].id
+	}
+	return s
+}
+
+// NewSymSpellFromFile create the index of the words of the given dictionary file, one word per line (the format used by SpellCheck)
+func NewSymSpellFromFile(filename string, maxDistance int) (*SymSpell, error) {
+	words, err := ReadAllFileInArrayE(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewSymSpell(maxDistance, DefaultSymSpellPrefixLength, words), nil
+}
+
+// Len return the number of words in the index
+func (s *SymSpell) Len() int {
+	return len(s.words)
+}
+
+// MaxDistance return the max distance supported by the index
+func (s *SymSpell) MaxDistance() int {
+	return s.maxDistance
+}
+
+// deletes call fn with the hash of every string obtained deleting up to maxDistance runes from the prefix of the word
+func (s *SymSpell) deletes(runes []rune, fn func(key uint64)) {
+	if len(runes) > s.prefixLength {
+		runes = runes[:s.prefixLength]
+	}
+	seen := make(map[uint64]struct{})
+	var generate func(current []rune, start, deleted int)
+	generate = func(current []rune, start, deleted int) {
+		key := hashRunes(current)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			fn(key)
+		}
+		if deleted == s.maxDistance || len(current) == 0 {
+			return
+		}
+		// Delete only after the last deleted position, in order to generate every combination once
+		next := make([]rune, len(current)-1)
+		for i := start; i < len(current); i++ {
+			copy(next, current[:i])
+			copy(next[i:], current[i+1:])
+			generate(next, i, deleted+1)
+		}
+	}
+	generate(runes, 0, 0)
+}
+
+// hashRunes return the FNV-1a hash of the given runes. The collisions only add candidates that are discarded by the distance check.
+func hashRunes(runes []rune) uint64 {
+	const offset, prime = 14695981039346656037, 1099511628211
+	hash := uint64(offset)
+	for _, r := range runes {
+		for shift := uint(0); shift < 32; shift += 8 {
+			hash ^= uint64(byte(r >> shift))
+			hash *= prime
+		}
+	}
+	return hash
+}
+
+// Search return all the words with a distance not greater than maxDistance from the given one, sorted by distance and word.
+// maxDistance is limited to the max distance of the index.
+func (s *SymSpell) Search(word string, maxDistance int) []Suggestion {
+	if maxDistance > s.maxDistance {
+		maxDistance = s.maxDistance
+	}
+	runes := []rune(strings.TrimSpace(word))
+	pattern := newMyersPattern(runes)
+	checked := make(map[int32]struct{})
+	var suggestions []Suggestion
+	s.deletes(runes, func(key uint64) {
+		for i := sort.Search(len(s.keys), func(i int) bool { return s.keys[i] >= key }); i < len(s.keys) && s.keys[i] == key; i++ {
+			id := s.ids[i]
+			if _, ok := checked[id]; ok {
+				continue
+			}
+			checked[id] = struct{}{}
+			if diff := len(s.runes[id]) - len(runes); diff > maxDistance || -diff > maxDistance {
+				continue
+			}
+			if distance := pattern.distance(s.runes[id]); distance <= maxDistance {
+				suggestions = append(suggestions, Suggestion{Word: s.words[id], Distance: float64(distance)})
+			}
+		}
+	})
+	sortSuggestions(suggestions)
+	return suggestions
+}
+
+// Best return the k words nearest to the given one, with a distance not greater than maxDistance
+func (s *SymSpell) Best(word string, k, maxDistance int) []Suggestion {
+	suggestions := s.Search(word, maxDistance)
+	if k > 0 && len(suggestions) > k {
+		suggestions = suggestions[:k]
+	}
+	return suggestions
+}
+
+// WriteTo serialize the index, so that can be loaded with ReadSymSpell without generate the deletes again
+func (s *SymSpell) WriteTo(w io.Writer) (int64, error) {
+	counter := &countWriter{w: w}
+	writer := bufio.NewWriter(counter)
+	writer.WriteString(symSpellMagic)
+	header := []uint64{uint64(s.maxDistance), uint64(s.prefixLength), uint64(len(s.words)), uint64(len(s.keys))}
+	if err := binary.Write(writer, binary.LittleEndian, header); err != nil {
+		return counter.n, err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	for _, word := range s.words {
+		writer.Write(buf[:binary.PutUvarint(buf[:], uint64(len(word)))])
+		writer.WriteString(word)
+	}
+	if err := binary.Write(writer, binary.LittleEndian, s.keys); err != nil {
+		return counter.n, err
+	}
+	if err := binary.Write(writer, binary.LittleEndian, s.ids); err != nil {
+		return counter.n, err
+	}
+	err := writer.Flush()
+	return counter.n, err
+}
+
+// readChunkSize is the max number of values allocated at once by readBytes, readUint64s and readInt32s
+const readChunkSize = 1 << 16
+
+// readBytes read n bytes, allocating the memory only for the bytes actually read
+func readBytes(r io.Reader, n uint64) ([]byte, error) {
+	var data []byte
+	for n > 0 {
+		chunk := make([]byte, minUint64(n, readChunkSize))
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		if data == nil {
+			data = chunk
+		} else {
+			data = append(data, chunk...)
+		}
+		n -= uint64(len(chunk))
+	}
+	return data, nil
+}
+
+// readUint64s read n little endian values, allocating the memory only for the values actually read
+func readUint64s(r io.Reader, n uint64) ([]uint64, error) {
+	var values []uint64
+	for n > 0 {
+		chunk := make([]uint64, minUint64(n, readChunkSize))
+		if err := binary.Read(r, binary.LittleEndian, chunk); err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = chunk
+		} else {
+			values = append(values, chunk...)
+		}
+		n -= uint64(len(chunk))
+	}
+	return values, nil
+}
+
+// readInt32s read n little endian values, allocating the memory only for the values actually read
+func readInt32s(r io.Reader, n uint64) ([]int32, error) {
+	var values []int32
+	for n > 0 {
+		chunk := make([]int32, minUint64(n, readChunkSize))
+		if err := binary.Read(r, binary.LittleEndian, chunk); err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = chunk
+		} else {
+			values = append(values, chunk...)
+		}
+		n -= uint64(len(chunk))
+	}
+	return values, nil
+}
+
+// ReadSymSpell load an index previously serialized with WriteTo
+func ReadSymSpell(r io.Reader) (*SymSpell, error) {
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(symSpellMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != symSpellMagic {
+		return nil, errors.New("ReadSymSpell: unsupported index format")
+	}
+	header := make([]uint64, 4)
+	if err := binary.Read(reader, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if header[0] > symSpellMaxDistance || header[1] <= header[0] || header[1] > symSpellMaxPrefixLength {
+		return nil, errors.New("ReadSymSpell: corrupted index")
+	}
+	s := &SymSpell{maxDistance: int(header[0]), prefixLength: int(header[1])}
+	// The buffers are not allocated using the counts of the header, that can be corrupted: they grow with the data read,
+	// so the memory used is bounded by the size of the input
+	for n := header[2]; n > 0; n-- {
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		word, err := readBytes(reader, length)
+		if err != nil {
+			return nil, err
+		}
+		s.words = append(s.words, string(word))
+		s.runes = append(s.runes, []rune(s.words[len(s.words)-1]))
+	}
+	if uint64(len(s.words)) > math.MaxInt32 {
+		return nil, errors.New("ReadSymSpell: corrupted index")
+	}
+	var err error
+	if s.keys, err = readUint64s(reader, header[3]); err != nil {
+		return nil, err
+	}
+	if s.ids, err = readInt32s(reader, header[3]); err != nil {
+		return nil, err
+	}
+	for _, id := range s.ids {
+		if id < 0 || int(id) >= len(s.words) {
+			return nil, errors.New("ReadSymSpell: corrupted index")
+		}
+	}
+	return s, nil
+}
+
+// SaveSymSpell serialize the index in the given file
+func SaveSymSpell(s *SymSpell, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return newFileError("create", filename, err)
+	}
+	_, err = s.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newFileError("write", filename, err)
+	}
+	return nil
+}
+
+// LoadSymSpell load the index from a file created by SaveSymSpell
+func LoadSymSpell(filename string) (*SymSpell, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, newFileError("open", filename, err)
+	}
+	defer file.Close()
+	s, err := ReadSymSpell(file)
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	return s, nil
+}
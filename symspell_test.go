@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// benchmarkWords is the size of the dictionary used by the benchmarks of the fuzzy indexes
+const benchmarkWords = 100000
+
+// randomWords return n distinct random words of 4-12 lowercase letters
+func randomWords(r *rand.Rand, n int) []string {
+	seen := make(map[string]struct{}, n)
+	words := make([]string, 0, n)
+	for len(words) < n {
+		word := make([]byte, 4+r.Intn(9))
+		for i := range word {
+			word[i] = 'a' + byte(r.Intn(26))
+		}
+		if _, ok := seen[string(word)]; !ok {
+			seen[string(word)] = struct{}{}
+			words = append(words, string(word))
+		}
+	}
+	return words
+}
+
+// typo return the word with a random rune replaced
+func typo(r *rand.Rand, word string) string {
+	b := []byte(word)
+	b[r.Intn(len(b))] = 'a' + byte(r.Intn(26))
+	return string(b)
+}
+
+func TestFuzzyIndexesAgree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := randomWords(r, 2000)
+	tree := NewBKTree(words...)
+	index := NewSymSpell(2, DefaultSymSpellPrefixLength, words)
+	for i := 0; i < 200; i++ {
+		query := typo(r, words[r.Intn(len(words))])
+		expected, actual := tree.Search(query, 2), index.Search(query, 2)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("%s: BKTree = %v, SymSpell = %v", query, expected, actual)
+		}
+	}
+}
+
+func TestReadBKTreeCorrupted(t *testing.T) {
+	encode := func(data bkTreeFile) *bytes.Buffer {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&data); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+	words := []string{"a", "b", "c"}
+	cases := map[string][][]bkEdge{
+		"self edge":      {{{Distance: 1, Node: 1}}, {{Distance: 1, Node: 1}}, nil},
+		"back edge":      {{{Distance: 1, Node: 1}}, {{Distance: 1, Node: 2}}, {{Distance: 1, Node: 1}}},
+		"root as child":  {{{Distance: 1, Node: 1}, {Distance: 2, Node: 2}}, {{Distance: 1, Node: 0}}, nil},
+		"two parents":    {{{Distance: 1, Node: 1}, {Distance: 2, Node: 2}}, {{Distance: 1, Node: 2}}, nil},
+		"orphan":         {{{Distance: 1, Node: 1}}, nil, nil},
+		"out of range":   {{{Distance: 1, Node: 1}, {Distance: 2, Node: 3}}, nil, nil},
+		"negative index": {{{Distance: 1, Node: -1}}, nil, nil},
+	}
+	for name, children := range cases {
+		if _, err := ReadBKTree(encode(bkTreeFile{Version: bkTreeVersion, Words: words, Children: children})); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	valid := [][]bkEdge{{{Distance: 1, Node: 1}}, {{Distance: 1, Node: 2}}, nil}
+	if _, err := ReadBKTree(encode(bkTreeFile{Version: bkTreeVersion, Words: words, Children: valid})); err != nil {
+		t.Error(err)
+	}
+
+	var buf bytes.Buffer
+	tree := NewBKTree(randomWords(rand.New(rand.NewSource(2)), 1000)...)
+	if _, err := tree.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadBKTree(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != tree.Len() || !reflect.DeepEqual(loaded.Search("abcde", 2), tree.Search("abcde", 2)) {
+		t.Error("the loaded BKTree differ from the original one")
+	}
+}
+
+func TestReadSymSpellCorrupted(t *testing.T) {
+	index := NewSymSpell(2, DefaultSymSpellPrefixLength, randomWords(rand.New(rand.NewSource(3)), 1000))
+	var buf bytes.Buffer
+	if _, err := index.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	loaded, err := ReadSymSpell(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != index.Len() || !reflect.DeepEqual(loaded.Search("abcde", 2), index.Search("abcde", 2)) {
+		t.Error("the loaded SymSpell differ from the original one")
+	}
+
+	// withHeader return the index with the given header
+	withHeader := func(header ...uint64) []byte {
+		corrupted := append([]byte{}, data...)
+		for i, value := range header {
+			binary.LittleEndian.PutUint64(corrupted[len(symSpellMagic)+8*i:], value)
+		}
+		return corrupted
+	}
+	cases := map[string][]byte{
+		"huge distance":      withHeader(1 << 40),
+		"prefix not longer":  withHeader(2, 2),
+		"huge prefix":        withHeader(2, 1<<20),
+		"huge words count":   withHeader(2, DefaultSymSpellPrefixLength, 1<<60),
+		"huge deletes count": withHeader(2, DefaultSymSpellPrefixLength, uint64(index.Len()), 1<<60),
+		"truncated":          data[:len(data)/2],
+		"invalid magic":      append([]byte("XXXX"), data[4:]...),
+	}
+	for name, corrupted := range cases {
+		if _, err := ReadSymSpell(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+var (
+	fuzzyBenchOnce  sync.Once
+	fuzzyBenchWords []string
+	fuzzyBenchTree  *BKTree
+	fuzzyBenchIndex *SymSpell
+)
+
+// fuzzyBenchmark run the search of words with a typo on the index of benchmarkWords words
+func fuzzyBenchmark(b *testing.B, search func(word string, maxDistance int) []Suggestion, maxDistance int) {
+	r := rand.New(rand.NewSource(4))
+	queries := make([]string, 1000)
+	for i := range queries {
+		queries[i] = typo(r, fuzzyBenchWords[r.Intn(len(fuzzyBenchWords))])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		search(queries[i%len(queries)], maxDistance)
+	}
+}
+
+// BenchmarkFuzzySearch compare the lookup of the BKTree and of the SymSpell on a dictionary of 100k words.
+// The SymSpell is faster (sub-millisecond also at distance 2), the BKTree use less memory and can be modified.
+func BenchmarkFuzzySearch(b *testing.B) {
+	fuzzyBenchOnce.Do(func() {
+		fuzzyBenchWords = randomWords(rand.New(rand.NewSource(5)), benchmarkWords)
+		fuzzyBenchTree = NewBKTree(fuzzyBenchWords...)
+		fuzzyBenchIndex = NewSymSpell(2, DefaultSymSpellPrefixLength, fuzzyBenchWords)
+	})
+	for _, maxDistance := range []int{1, 2} {
+		distance := string('0' + byte(maxDistance))
+		b.Run("BKTree/d="+distance, func(b *testing.B) { fuzzyBenchmark(b, fuzzyBenchTree.Search, maxDistance) })
+		b.Run("SymSpell/d="+distance, func(b *testing.B) { fuzzyBenchmark(b, fuzzyBenchIndex.Search, maxDistance) })
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// tailChunkSize is the size of the block read backward from the end of the file while looking for the new lines
+const tailChunkSize = 64 * 1024
+
+// LineFilter is delegated to select the lines that have to be returned by the tail functions.
+// A line is kept when it matches Include (if set) and does not match Exclude (if set).
+type LineFilter struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// NewLineFilter compile the given pattern in a case insensitive way (like 'egrep -i').
+// If reverse is true, the lines that match the pattern are discarded (like 'egrep -v').
+func NewLineFilter(pattern string, reverse bool) (*LineFilter, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		return &LineFilter{Exclude: re}, nil
+	}
+	return &LineFilter{Include: re}, nil
+}
+
+// Match verify if the given line (without the new line) satisfy the filter
+func (f *LineFilter) Match(line []byte) bool {
+	if f == nil {
+		return true
+	}
+	if f.Include != nil && !f.Include.Match(line) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.Match(line) {
+		return false
+	}
+	return true
+}
+
+// TailOffset return the offset of the first byte of the last 'lines' lines of the given reader.
+// The data are read backward starting from 'size', so only the tail of the content is loaded.
+func TailOffset(r io.ReaderAt, size int64, lines int) (int64, error) {
+	if lines <= 0 || size <= 0 {
+		return size, nil
+	}
+	buf := make([]byte, tailChunkSize)
+	found := 0
+	for end := size; end > 0; {
+		start := end - tailChunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if n, err := r.ReadAt(chunk, start); n < len(chunk) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return -1, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			// The new line at the end of the data close the last line, does not start a new one
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			if found++; found == lines {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// TailData return the last 'lines' lines of the first 'size' bytes of the given reader
+func TailData(r io.ReaderAt, size int64, lines int) ([]byte, error) {
+	offset, err := TailOffset(r, size, lines)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size-offset)
+	if n, err := r.ReadAt(data, offset); n < len(data) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// TailFile return the last 'lines' lines of the given file, without spawning any external process
+func TailFile(filename string, lines int) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return TailData(file, info.Size(), lines)
+}
+
+// TailFileFilter return the lines that satisfy the filter among the last 'lines' lines of the given file
+func TailFileFilter(filename string, lines int, filter *LineFilter) ([]byte, error) {
+	data, err := TailFile(filename, lines)
+	if err != nil {
+		return nil, err
+	}
+	return FilterLines(data, filter), nil
+}
+
+// FilterLines return only the lines of data that satisfy the filter.
+// Every returned line is terminated by a new line, like the 'grep' output.
+func FilterLines(data []byte, filter *LineFilter) []byte {
+	var out bytes.Buffer
+	for len(data) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+		if filter.Match(line) {
+			out.Write(line)
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,100 @@
+//go:build cgo
+// +build cgo
+
+package utils
+
+import (
+	"errors"
+	"io"
+
+	"github.com/valyala/gozstd"
+)
+
+// zstdCompress compress the given data using the zstd C library
+func zstdCompress(src []byte) []byte {
+	return gozstd.Compress(nil, src)
+}
+
+// zstdWriter release the C resources of the gozstd writer on Close
+type zstdWriter struct {
+	*gozstd.Writer
+}
+
+func (w zstdWriter) Close() error {
+	err := w.Writer.Close()
+	w.Writer.Release()
+	return err
+}
+
+// newZstdWriter return a streaming zstd compressor, level 0 means the default level
+func newZstdWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gozstd.DefaultCompressionLevel
+	}
+	return zstdWriter{gozstd.NewWriterLevel(w, level)}, nil
+}
+
+// zstdReader release the C resources of the gozstd reader on Close
+type zstdReader struct {
+	*gozstd.Reader
+}
+
+func (r zstdReader) Close() error {
+	r.Reader.Release()
+	return nil
+}
+
+// newZstdReader return a streaming zstd decompressor
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	return zstdReader{gozstd.NewReader(r)}, nil
+}
+
+// zstdDecompress decompress the given zstd frame using the zstd C library
+func zstdDecompress(src []byte) ([]byte, error) {
+	return gozstd.Decompress(nil, src)
+}
+
+// zstdDictCodec contains the dictionary prepared by the zstd C library
+type zstdDictCodec struct {
+	cdict *gozstd.CDict
+	ddict *gozstd.DDict
+}
+
+// newZstdDictCodec prepare the dictionary for the compression and the decompression
+func newZstdDictCodec(dict []byte, level int) (*zstdDictCodec, error) {
+	if level == 0 {
+		level = gozstd.DefaultCompressionLevel
+	}
+	cdict, err := gozstd.NewCDictLevel(dict, level)
+	if err != nil {
+		return nil, err
+	}
+	ddict, err := gozstd.NewDDict(dict)
+	if err != nil {
+		cdict.Release()
+		return nil, err
+	}
+	return &zstdDictCodec{cdict: cdict, ddict: ddict}, nil
+}
+
+func (c *zstdDictCodec) compress(src []byte) []byte {
+	return gozstd.CompressDict(nil, src, c.cdict)
+}
+
+func (c *zstdDictCodec) decompress(src []byte) ([]byte, error) {
+	return gozstd.DecompressDict(nil, src, c.ddict)
+}
+
+func (c *zstdDictCodec) release() {
+	c.cdict.Release()
+	c.ddict.Release()
+}
+
+// trainZstdDict train the dictionary using the zstd C library
+func trainZstdDict(samples [][]byte, size int) ([]byte, error) {
+	dict := gozstd.BuildDict(samples, size)
+	if len(dict) == 0 {
+		return nil, errors.New("unable to train the zstd dictionary, provide more samples")
+	}
+	return dict, nil
+}
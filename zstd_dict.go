@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"sync"
+)
+
+const (
+	// zstdDictMagic is the magic number at the start of a dictionary created by the zstd trainer
+	zstdDictMagic = 0xEC30A437
+	// zstdFrameMagic is the magic number at the start of a zstd frame
+	zstdFrameMagic = 0xFD2FB528
+)
+
+// DefaultZstdDictSize is the size of the dictionaries trained by TrainZstdDictFromFiles when not specified.
+// It is the default of the zstd command line tool.
+const DefaultZstdDictSize = 110 << 10
+
+var (
+	// ErrZstdDictUnsupported is returned by the dictionary functions when the package is built without cgo
+	ErrZstdDictUnsupported = errors.New("zstd dictionaries require cgo")
+	// ErrZstdDictMismatch is returned when a frame was compressed with a dictionary different from the given one
+	ErrZstdDictMismatch = errors.New("zstd frame compressed with a different dictionary")
+	// ErrZstdDictNotFound is returned by the ZstdDictRegistry when the dictionary of a frame is not registered
+	ErrZstdDictNotFound = errors.New("zstd dictionary not found")
+	// ErrZstdFrameInvalid is returned when the data are not a zstd frame
+	ErrZstdFrameInvalid = errors.New("invalid zstd frame")
+)
+
+// ZstdDict is a trained zstd dictionary, ready to compress and decompress the data.
+// The prepared dictionaries (CDict/DDict) are created only once, so the dictionary can be reused for many small payloads.
+// The ID of the dictionary is written in every compressed frame, so the receiver knows which dictionary is needed.
+// A ZstdDict is safe for concurrent use.
+type ZstdDict struct {
+	// ID is the identifier of the dictionary, 0 for a raw content dictionary
+	ID    uint32
+	Data  []byte
+	Level int
+	codec *zstdDictCodec
+}
+
+// NewZstdDict prepare the given dictionary for compress at the given level (0 for the default level)
+func NewZstdDict(data []byte, level int) (*ZstdDict, error) {
+	codec, err := newZstdDictCodec(data, level)
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdDict{ID: ZstdDictID(data), Data: data, Level: level, codec: codec}, nil
+}
+
+// Compress compress the given data with the dictionary
+func (d *ZstdDict) Compress(src []byte) []byte {
+	return d.codec.compress(src)
+}
+
+// Decompress decompress a frame compressed with the dictionary.
+// ErrZstdDictMismatch is returned if the frame declare a different dictionary.
+func (d *ZstdDict) Decompress(src []byte) ([]byte, error) {
+	id, err := ZstdFrameDictID(src)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 && id != d.ID {
+		return nil, ErrZstdDictMismatch
+	}
+	return d.codec.decompress(src)
+}
+
+// Release free the C resources of the dictionary, it can not be used after the call
+func (d *ZstdDict) Release() {
+	d.codec.release()
+}
+
+// ZstdDictID return the ID of a dictionary created by the zstd trainer, 0 for a raw content dictionary
+func ZstdDictID(dict []byte) uint32 {
+	if len(dict) < 8 || binary.LittleEndian.Uint32(dict) != zstdDictMagic {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(dict[4:])
+}
+
+// ZstdFrameDictID return the ID of the dictionary declared in the header of the given zstd frame, 0 if no dictionary is declared
+func ZstdFrameDictID(frame []byte) (uint32, error) {
+	if len(frame) < 5 || binary.LittleEndian.Uint32(frame) != zstdFrameMagic {
+		return 0, ErrZstdFrameInvalid
+	}
+	descriptor := frame[4]
+	offset := 5
+	// The window descriptor is present only for the frames that are not single segment
+	if descriptor&0x20 == 0 {
+		offset++
+	}
+	size := [4]int{0, 1, 2, 4}[descriptor&0x03]
+	if len(frame) < offset+size {
+		return 0, ErrZstdFrameInvalid
+	}
+	var id uint32
+	for i := size - 1; i >= 0; i-- {
+		id = id<<8 | uint32(frame[offset+i])
+	}
+	return id, nil
+}
+
+// TrainZstdDict train a dictionary of (about) the given size from the samples.
+// The samples should be similar to the data that will be compressed, ex: a log snippet for every sample.
+func TrainZstdDict(samples [][]byte, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultZstdDictSize
+	}
+	return trainZstdDict(samples, size)
+}
+
+// TrainZstdDictFromFiles train a dictionary using every given file as a sample
+func TrainZstdDictFromFiles(filenames []string, size int) ([]byte, error) {
+	samples := make([][]byte, 0, len(filenames))
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, newFileError("read", filename, err)
+		}
+		samples = append(samples, data)
+	}
+	return TrainZstdDict(samples, size)
+}
+
+// SaveZstdDict write the dictionary in the given file
+func SaveZstdDict(filename string, dict []byte) error {
+	if err := ioutil.WriteFile(filename, dict, 0644); err != nil {
+		return newFileError("write", filename, err)
+	}
+	return nil
+}
+
+// LoadZstdDict load and prepare the dictionary saved in the given file
+func LoadZstdDict(filename string, level int) (*ZstdDict, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newFileError("read", filename, err)
+	}
+	return NewZstdDict(data, level)
+}
+
+// ZstdDictRegistry contains the dictionaries by ID.
+// The data are compressed with the current dictionary and decompressed with the dictionary declared in the frame,
+// so the old frames can be read after the training of a new dictionary.
+type ZstdDictRegistry struct {
+	mu      sync.RWMutex
+	dicts   map[uint32]*ZstdDict
+	current *ZstdDict
+}
+
+// NewZstdDictRegistry return an empty registry
+func NewZstdDictRegistry() *ZstdDictRegistry {
+	return &ZstdDictRegistry{dicts: make(map[uint32]*ZstdDict)}
+}
+
+// Register add the dictionary to the registry and use it for the next compressions
+func (r *ZstdDictRegistry) Register(dict *ZstdDict) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dicts[dict.ID] = dict
+	r.current = dict
+}
+
+// LoadFile load the dictionary saved in the given file and register it
+func (r *ZstdDictRegistry) LoadFile(filename string, level int) (*ZstdDict, error) {
+	dict, err := LoadZstdDict(filename, level)
+	if err != nil {
+		return nil, err
+	}
+	r.Register(dict)
+	return dict, nil
+}
+
+// Get return the dictionary with the given ID
+func (r *ZstdDictRegistry) Get(id uint32) (*ZstdDict, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dict, ok := r.dicts[id]
+	return dict, ok
+}
+
+// Current return the dictionary used for compress, nil if the registry is empty
+func (r *ZstdDictRegistry) Current() *ZstdDict {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Compress compress the data with the current dictionary, returning the ID of the dictionary used.
+// Without dictionaries the data are compressed without dictionary and the ID is 0.
+func (r *ZstdDictRegistry) Compress(src []byte) ([]byte, uint32) {
+	dict := r.Current()
+	if dict == nil {
+		return zstdCompress(src), 0
+	}
+	return dict.Compress(src), dict.ID
+}
+
+// Decompress decompress the frame with the dictionary declared in its header
+func (r *ZstdDictRegistry) Decompress(src []byte) ([]byte, error) {
+	id, err := ZstdFrameDictID(src)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return zstdDecompress(src)
+	}
+	dict, ok := r.Get(id)
+	if !ok {
+		return nil, ErrZstdDictNotFound
+	}
+	return dict.Decompress(src)
+}
+
+// FilterFromFileCompressDict is like FilterFromFileCompress, but compress the data with the given dictionary.
+// The dictionary improve a lot the compression of the small outputs.
+func FilterFromFileCompressDict(filename string, maxLinesToSearch int, toFilter string, reverse bool, dict *ZstdDict) ([]byte, error) {
+	filter, err := NewLineFilter(toFilter, reverse)
+	if err != nil {
+		return nil, err
+	}
+	data, err := TailFileFilter(filename, maxLinesToSearch, filter)
+	if err != nil {
+		return nil, err
+	}
+	return dict.Compress(data), nil
+}
@@ -0,0 +1,74 @@
+//go:build !cgo
+// +build !cgo
+
+package utils
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdEncoder is the pure Go zstd encoder, safe for concurrent use of EncodeAll
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+// zstdDecoder is the pure Go zstd decoder, safe for concurrent use of DecodeAll
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// zstdCompress compress the given data using the pure Go zstd implementation
+func zstdCompress(src []byte) []byte {
+	return zstdEncoder.EncodeAll(src, nil)
+}
+
+// newZstdWriter return a streaming zstd compressor, level 0 means the default level
+func newZstdWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		return zstd.NewWriter(w)
+	}
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+}
+
+// zstdReader adapt the Close of the decoder to the io.Closer interface
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r zstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+// newZstdReader return a streaming zstd decompressor
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zstdReader{decoder}, nil
+}
+
+// zstdDecompress decompress the given zstd frame using the pure Go zstd implementation
+func zstdDecompress(src []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(src, nil)
+}
+
+// zstdDictCodec is not available without cgo: the pure Go implementation does not support the dictionaries
+type zstdDictCodec struct{}
+
+func newZstdDictCodec(dict []byte, level int) (*zstdDictCodec, error) {
+	return nil, ErrZstdDictUnsupported
+}
+
+func (c *zstdDictCodec) compress(src []byte) []byte {
+	return nil
+}
+
+func (c *zstdDictCodec) decompress(src []byte) ([]byte, error) {
+	return nil, ErrZstdDictUnsupported
+}
+
+func (c *zstdDictCodec) release() {}
+
+func trainZstdDict(samples [][]byte, size int) ([]byte, error) {
+	return nil, ErrZstdDictUnsupported
+}